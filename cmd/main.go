@@ -9,12 +9,16 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 
 	"google-indexing-api/internal/config"
 	"google-indexing-api/internal/handlers"
 	"google-indexing-api/internal/middleware"
+	"google-indexing-api/internal/models"
 	"google-indexing-api/internal/services"
+	"google-indexing-api/internal/store"
 	"google-indexing-api/pkg/utils"
 )
 
@@ -32,17 +36,69 @@ func main() {
 	// Set Gin mode
 	gin.SetMode(cfg.Server.GinMode)
 
+	// Submission history and batch jobs
+	var history store.Store
+	if cfg.Store.Path != "" {
+		boltStore, err := store.OpenBoltStore(cfg.Store.Path, logger)
+		if err != nil {
+			logger.Fatal("Failed to open store: ", err)
+		}
+		defer boltStore.Close()
+		history = boltStore
+	} else {
+		logger.Warn("STORE_PATH is empty, submission history and jobs are not persisted")
+	}
+
 	// Initialize Google Indexing Service
-	indexingService, err := services.NewGoogleIndexingService(logger)
+	indexingService, err := services.NewGoogleIndexingService(history, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize Google Indexing Service: ", err)
 	}
 
+	// Registered service accounts, encrypted with the master keys
+	keyring, err := services.LoadKeyring(services.KeyringConfig{
+		MasterKey:          cfg.Credentials.MasterKey,
+		PreviousMasterKeys: cfg.Credentials.PreviousMasterKeys,
+		MasterKeyFile:      cfg.Credentials.MasterKeyFile,
+	})
+	if err != nil {
+		logger.Fatal("Failed to load master keys: ", err)
+	}
+
+	credentialStore, err := services.NewCredentialStore(cfg.Credentials.StorePath, keyring, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize credential store: ", err)
+	}
+
+	// Named pools of registered service accounts
+	poolStore, err := services.NewPoolStore(cfg.Credentials.PoolStorePath, credentialStore, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize pool store: ", err)
+	}
+
+	// API keys allowed to call /api/v1
+	apiKeyStore, err := services.NewAPIKeyStore(cfg.Auth.KeyStorePath, cfg.Auth.APIKey, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize API key store: ", err)
+	}
+
+	// Background batch jobs
+	jobManager := services.NewJobManager(indexingService, history, time.Duration(cfg.Jobs.RetentionMinutes)*time.Minute, logger)
+
 	// Initialize handlers
-	indexingHandler := handlers.NewIndexingHandler(indexingService, logger)
+	indexingHandler := handlers.NewIndexingHandler(indexingService, jobManager, credentialStore, poolStore, logger)
+	credentialsHandler := handlers.NewCredentialsHandler(credentialStore, logger)
+	poolsHandler := handlers.NewPoolsHandler(poolStore, logger)
+	apiKeysHandler := handlers.NewAPIKeysHandler(apiKeyStore, logger)
+	historyHandler := handlers.NewHistoryHandler(history, logger)
+
+	// Expose cache and worker pool state on /metrics
+	if cfg.Security.EnableMetrics {
+		prometheus.MustRegister(indexingService.Collectors()...)
+	}
 
 	// Setup router
-	router := setupRouter(indexingHandler, logger)
+	router := setupRouter(indexingHandler, credentialsHandler, poolsHandler, apiKeysHandler, historyHandler, apiKeyStore, logger)
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -72,13 +128,31 @@ func main() {
 		logger.Fatal("Server forced to shutdown: ", err)
 	}
 
+	// Running jobs are canceled; their unsent URLs are reported as canceled
+	if err := jobManager.Shutdown(ctx); err != nil {
+		logger.WithError(err).Warn("Batch jobs did not stop in time")
+	}
+
 	logger.Info("Server exited")
 }
 
-func setupRouter(indexingHandler *handlers.IndexingHandler, logger *logrus.Logger) *gin.Engine {
+func setupRouter(indexingHandler *handlers.IndexingHandler, credentialsHandler *handlers.CredentialsHandler, poolsHandler *handlers.PoolsHandler, apiKeysHandler *handlers.APIKeysHandler, historyHandler *handlers.HistoryHandler, apiKeyStore *services.APIKeyStore, logger *logrus.Logger) *gin.Engine {
+	cfg := config.GetConfig()
 	router := gin.New()
 
+	// Only trust X-Forwarded-For from known proxies, so ClientIP in logs and
+	// rate limiting cannot be spoofed
+	if err := router.SetTrustedProxies(cfg.Security.TrustedProxies); err != nil {
+		logger.Fatal("Invalid TRUSTED_PROXIES: ", err)
+	}
+
 	// Middleware
+	if cfg.Security.EnableMetrics {
+		router.Use(middleware.Metrics())
+	}
+	if cfg.Security.EnableSecurityHeaders {
+		router.Use(middleware.SecurityHeaders(cfg.Security.HSTSMaxAgeSeconds))
+	}
 	router.Use(middleware.CORS())
 	router.Use(middleware.RequestLogger(logger))
 	router.Use(middleware.ErrorHandler(logger))
@@ -87,26 +161,92 @@ func setupRouter(indexingHandler *handlers.IndexingHandler, logger *logrus.Logge
 	// Health check endpoint (publicly accessible)
 	router.GET("/api/health", indexingHandler.HealthCheck)
 
-	// API routes (no authentication required)
+	// Prometheus metrics (publicly accessible, restrict at the network level)
+	if cfg.Security.EnableMetrics {
+		router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	}
+
+	// API routes (API key required)
 	api := router.Group("/api/v1")
+	api.Use(middleware.FailedAuthLimit(cfg.RateLimit.AuthFailuresPerMinute))
+	api.Use(middleware.APIKeyAuth(apiKeyStore, logger))
+	api.Use(middleware.RateLimit(cfg.RateLimit.PerMinute))
+
+	// Submitter routes
+	submit := api.Group("")
+	submit.Use(middleware.RequireRole(models.RoleSubmitter))
 	{
 		// Single URL indexing
-		api.POST("/index", indexingHandler.SubmitURL)
+		submit.POST("/index", indexingHandler.SubmitURL)
 
-		// Batch URL indexing
-		api.POST("/index/batch", indexingHandler.SubmitURLsBatch)
+		// Single URL removal
+		submit.DELETE("/index", indexingHandler.DeleteURL)
 
 		// URL status check
-		api.GET("/status/*url", func(c *gin.Context) {
-			// Extract URL from path parameter
-			url := c.Param("url")[1:] // Remove leading slash
-			c.Params = gin.Params{{Key: "url", Value: url}}
-			indexingHandler.GetURLStatus(c)
-		})
+		submit.POST("/status", indexingHandler.GetURLStatus)
+
+		// Batch job progress and cancellation
+		submit.GET("/jobs/:id", indexingHandler.GetJob)
+		submit.DELETE("/jobs/:id", indexingHandler.CancelJob)
+	}
 
+	// Batch routes fan out to many Google calls, so they get a stricter limit
+	batch := submit.Group("")
+	batch.Use(middleware.RateLimit(cfg.RateLimit.BatchPerMinute))
+	{
+		// Batch URL indexing
+		batch.POST("/index/batch", indexingHandler.SubmitURLsBatch)
+
+		// Batch URL status check
+		batch.POST("/status/batch", indexingHandler.GetURLStatusBatch)
+
+		// Asynchronous batch jobs
+		batch.POST("/jobs", indexingHandler.SubmitJob)
+	}
+
+	// Viewer routes
+	view := api.Group("")
+	view.Use(middleware.RequireRole(models.RoleViewer))
+	{
+		// Cache statistics
+		view.GET("/cache/stats", indexingHandler.GetCacheStats)
+
+		// Submission history
+		view.GET("/history", historyHandler.GetHistory)
+
+		// Daily quota usage
+		view.GET("/quota", indexingHandler.GetQuota)
+	}
+
+	// Admin routes
+	admin := api.Group("")
+	admin.Use(middleware.RequireRole(models.RoleAdmin))
+	{
 		// Cache management
-		api.GET("/cache/stats", indexingHandler.GetCacheStats)
-		api.POST("/cache/clear", indexingHandler.ClearCache)
+		admin.POST("/cache/clear", indexingHandler.ClearCache)
+
+		// Registered service accounts
+		admin.POST("/credentials", credentialsHandler.RegisterCredential)
+		admin.GET("/credentials", credentialsHandler.ListCredentials)
+		admin.GET("/credentials/:id", credentialsHandler.GetCredential)
+		admin.POST("/credentials/:id/rotate", credentialsHandler.RotateCredential)
+		admin.PUT("/credentials/:id/allowed-urls", credentialsHandler.SetAllowedURLs)
+		admin.DELETE("/credentials/:id", credentialsHandler.DeleteCredential)
+
+		// Pools of registered service accounts
+		admin.POST("/pools", poolsHandler.CreatePool)
+		admin.GET("/pools", poolsHandler.ListPools)
+		admin.GET("/pools/:name", poolsHandler.GetPool)
+		admin.PUT("/pools/:name", poolsHandler.UpdatePool)
+		admin.DELETE("/pools/:name", poolsHandler.DeletePool)
+
+		// Master key rotation
+		admin.POST("/master-key/rotate", credentialsHandler.RotateMasterKey)
+
+		// API key management
+		admin.POST("/keys", apiKeysHandler.CreateAPIKey)
+		admin.GET("/keys", apiKeysHandler.ListAPIKeys)
+		admin.DELETE("/keys/:id", apiKeysHandler.RevokeAPIKey)
 	}
 
 	return router
@@ -7,6 +7,13 @@ APP_NAME="google-indexing-api"
 
 echo "🚀 Starting deployment of $APP_NAME..."
 
+# Every /api/v1 route needs an API key; fail before stopping the running
+# containers if none is configured
+if ! grep -qE '^API_KEY=.+' .env 2>/dev/null && [ -z "$API_KEY" ]; then
+    echo "❌ API_KEY is not set. Add it to .env, e.g.: echo \"API_KEY=\$(openssl rand -hex 24)\" >> .env"
+    exit 1
+fi
+
 # Stop existing containers
 echo "📦 Stopping existing containers..."
 docker-compose down || true
@@ -1,10 +1,12 @@
 #!/bin/bash
 
 # Google Indexing API Test Scripts
-# Make sure to set your API_KEY and BASE_URL before running
+# Make sure to set your API_KEY, CREDENTIAL_ID and BASE_URL before running
 
 BASE_URL="http://localhost:8080"
 API_KEY="your-api-key-here"
+# ID of a service account registered through POST /api/v1/credentials
+CREDENTIAL_ID="your-credential-id-here"
 
 # Colors for output
 RED='\033[0;31m'
@@ -78,10 +80,13 @@ test_batch_urls() {
 test_url_status() {
     print_test "Testing URL Status Check"
     
-    encoded_url=$(echo "https://example.com/test-page" | jq -sRr @uri)
-    
-    response=$(curl -s "$BASE_URL/api/v1/status/$encoded_url" \
-        -H "Authorization: Bearer $API_KEY")
+    response=$(curl -s -X POST "$BASE_URL/api/v1/status" \
+        -H "Content-Type: application/json" \
+        -H "Authorization: Bearer $API_KEY" \
+        -d '{
+            "url": "https://example.com/test-page",
+            "credential_id": "'"$CREDENTIAL_ID"'"
+        }')
     
     echo "$response" | jq .
     echo ""
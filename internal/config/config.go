@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"strconv"
 	"strings"
@@ -24,26 +25,64 @@ type Config struct {
 		EnableRequestLog bool
 	}
 	RateLimit struct {
-		PerMinute int
+		PerMinute             int
+		BatchPerMinute        int
+		AuthFailuresPerMinute int
 	}
 	CORS struct {
-		AllowedOrigins []string
-		AllowedMethods []string
-		AllowedHeaders []string
+		AllowedOrigins   []string
+		AllowedMethods   []string
+		AllowedHeaders   []string
+		ExposedHeaders   []string
+		AllowCredentials bool
+		MaxAgeSeconds    int
 	}
 	Performance struct {
 		CacheTTLMinutes       int
+		CacheMaxEntries       int
 		MaxConcurrentRequests int
 		RequestTimeoutSeconds int
 		MaxBatchSize          int
 		MaxRetryAttempts      int
 		RetryDelaySeconds     int
 	}
+	Outbound struct {
+		PerMinute int
+		PerSecond int
+	}
 	Security struct {
 		EnableSecurityHeaders bool
+		HSTSMaxAgeSeconds     int
 		TrustedProxies        []string
 		EnableMetrics         bool
 	}
+	Store struct {
+		Path string
+	}
+	Jobs struct {
+		MaxURLs          int
+		RetentionMinutes int
+	}
+	Quota struct {
+		DailyLimit    int
+		ProjectLimits map[string]int
+	}
+	Ownership struct {
+		CheckEnabled          bool
+		CacheTTLMinutes       int
+		SearchConsoleEndpoint string
+	}
+	Auth struct {
+		APIKey       string
+		KeyStorePath string
+	}
+	Credentials struct {
+		StorePath          string
+		PoolStorePath      string
+		MasterKey          string
+		PreviousMasterKeys []string
+		MasterKeyFile      string
+	}
 }
 
 var AppConfig *Config
@@ -71,6 +110,8 @@ func LoadConfig() error {
 
 	// Rate limiting configuration
 	config.RateLimit.PerMinute = getEnvInt("RATE_LIMIT_PER_MINUTE", 60)
+	config.RateLimit.BatchPerMinute = getEnvInt("RATE_LIMIT_BATCH_PER_MINUTE", 10)
+	config.RateLimit.AuthFailuresPerMinute = getEnvInt("RATE_LIMIT_AUTH_FAILURES_PER_MINUTE", 10)
 
 	// CORS configuration
 	originsStr := getEnv("CORS_ALLOWED_ORIGINS", "*")
@@ -83,23 +124,94 @@ func LoadConfig() error {
 	methodsStr := getEnv("CORS_ALLOWED_METHODS", "GET,POST,PUT,DELETE,OPTIONS")
 	config.CORS.AllowedMethods = strings.Split(methodsStr, ",")
 
-	headersStr := getEnv("CORS_ALLOWED_HEADERS", "Content-Type,Authorization,X-Requested-With")
+	headersStr := getEnv("CORS_ALLOWED_HEADERS", "Content-Type,Authorization,X-Requested-With,X-API-Key")
 	config.CORS.AllowedHeaders = strings.Split(headersStr, ",")
 
+	exposedHeadersStr := getEnv("CORS_EXPOSED_HEADERS", "X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset,Retry-After")
+	config.CORS.ExposedHeaders = strings.Split(exposedHeadersStr, ",")
+
+	config.CORS.AllowCredentials = getEnvBool("CORS_ALLOW_CREDENTIALS", false)
+	config.CORS.MaxAgeSeconds = getEnvInt("CORS_MAX_AGE_SECONDS", 600)
+
+	// Allowing credentials for every origin would let any site make
+	// authenticated requests on behalf of a visitor
+	if config.CORS.AllowCredentials {
+		for _, origin := range config.CORS.AllowedOrigins {
+			if strings.TrimSpace(origin) == "*" {
+				return errors.New("CORS_ALLOW_CREDENTIALS=true cannot be combined with CORS_ALLOWED_ORIGINS=*, list the allowed origins instead")
+			}
+		}
+	}
+
 	// Performance configuration
 	config.Performance.CacheTTLMinutes = getEnvInt("CACHE_TTL_MINUTES", 60)
+	config.Performance.CacheMaxEntries = getEnvInt("CACHE_MAX_ENTRIES", 100)
 	config.Performance.MaxConcurrentRequests = getEnvInt("MAX_CONCURRENT_REQUESTS", 10)
 	config.Performance.RequestTimeoutSeconds = getEnvInt("REQUEST_TIMEOUT_SECONDS", 30)
 	config.Performance.MaxBatchSize = getEnvInt("MAX_BATCH_SIZE", 100)
 	config.Performance.MaxRetryAttempts = getEnvInt("MAX_RETRY_ATTEMPTS", 3)
 	config.Performance.RetryDelaySeconds = getEnvInt("RETRY_DELAY_SECONDS", 2)
 
+	// Outbound rate limit toward Google, per project. 0 disables a limit
+	config.Outbound.PerMinute = getEnvInt("GOOGLE_RATE_LIMIT_PER_MINUTE", 600)
+	config.Outbound.PerSecond = getEnvInt("GOOGLE_RATE_LIMIT_PER_SECOND", 10)
+
 	// Security configuration
 	config.Security.EnableSecurityHeaders = getEnvBool("ENABLE_SECURITY_HEADERS", true)
+	config.Security.HSTSMaxAgeSeconds = getEnvInt("HSTS_MAX_AGE_SECONDS", 31536000)
 	config.Security.EnableMetrics = getEnvBool("ENABLE_METRICS", true)
 
+	// "none" trusts no proxy, so ClientIP is always the connection address
 	trustedProxiesStr := getEnv("TRUSTED_PROXIES", "127.0.0.1")
-	config.Security.TrustedProxies = strings.Split(trustedProxiesStr, ",")
+	if trustedProxiesStr != "none" {
+		for _, proxy := range strings.Split(trustedProxiesStr, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				config.Security.TrustedProxies = append(config.Security.TrustedProxies, proxy)
+			}
+		}
+	}
+
+	// History store configuration
+	config.Store.Path = getEnv("STORE_PATH", "data/indexing.db")
+
+	// Batch job configuration
+	config.Jobs.MaxURLs = getEnvInt("JOB_MAX_URLS", 10000)
+	config.Jobs.RetentionMinutes = getEnvInt("JOB_RETENTION_MINUTES", 1440)
+
+	// Daily publish quota, per Google Cloud project. 0 disables the limit
+	config.Quota.DailyLimit = getEnvInt("QUOTA_DAILY_LIMIT", 200)
+	config.Quota.ProjectLimits = make(map[string]int)
+
+	if projectLimitsStr := getEnv("QUOTA_PROJECT_LIMITS", ""); projectLimitsStr != "" {
+		for _, entry := range strings.Split(projectLimitsStr, ",") {
+			projectID, limitStr, found := strings.Cut(strings.TrimSpace(entry), "=")
+			limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+			if !found || projectID == "" || err != nil || limit < 0 {
+				logrus.WithField("entry", entry).Warn("Ignoring invalid QUOTA_PROJECT_LIMITS entry, expected project-id=limit")
+				continue
+			}
+			config.Quota.ProjectLimits[strings.TrimSpace(projectID)] = limit
+		}
+	}
+
+	// Search Console ownership pre-check. An empty endpoint uses Google's
+	config.Ownership.CheckEnabled = getEnvBool("OWNERSHIP_CHECK_ENABLED", false)
+	config.Ownership.CacheTTLMinutes = getEnvInt("OWNERSHIP_CACHE_TTL_MINUTES", 60)
+	config.Ownership.SearchConsoleEndpoint = getEnv("SEARCH_CONSOLE_ENDPOINT", "")
+
+	// Authentication configuration
+	config.Auth.APIKey = getEnv("API_KEY", "")
+	config.Auth.KeyStorePath = getEnv("API_KEY_STORE_PATH", "data/api_keys.json")
+
+	// Credential store configuration
+	config.Credentials.StorePath = getEnv("CREDENTIAL_STORE_PATH", "data/credentials.json")
+	config.Credentials.PoolStorePath = getEnv("POOL_STORE_PATH", "data/pools.json")
+	config.Credentials.MasterKey = getEnv("MASTER_KEY", "")
+	config.Credentials.MasterKeyFile = getEnv("MASTER_KEY_FILE", "data/master.key")
+
+	if previousKeysStr := getEnv("MASTER_KEY_PREVIOUS", ""); previousKeysStr != "" {
+		config.Credentials.PreviousMasterKeys = strings.Split(previousKeysStr, ",")
+	}
 
 	AppConfig = config
 	return nil
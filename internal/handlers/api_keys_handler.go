@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/internal/services"
+)
+
+type APIKeysHandler struct {
+	store  *services.APIKeyStore
+	logger *logrus.Logger
+}
+
+func NewAPIKeysHandler(store *services.APIKeyStore, logger *logrus.Logger) *APIKeysHandler {
+	return &APIKeysHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// @Summary Create an API key
+// @Description Issue a new API key. The key is only returned in this response
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param request body models.APIKeyRequest true "API key name, roles and optional expiry"
+// @Success 201 {object} models.APIKeyCreatedResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/keys [post]
+func (h *APIKeysHandler) CreateAPIKey(c *gin.Context) {
+	var req models.APIKeyRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid request format: name and at least one role (submitter, viewer, admin) are required",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "expires_at must be in the future",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	created, err := h.store.Create(req.Name, req.Roles, req.ExpiresAt)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create API key")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to create API key",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// @Summary List API keys
+// @Description List API keys, including expired and revoked ones, without the keys themselves
+// @Tags api-keys
+// @Produce json
+// @Success 200 {object} models.APIKeyListResponse
+// @Router /api/v1/keys [get]
+func (h *APIKeysHandler) ListAPIKeys(c *gin.Context) {
+	keys := h.store.List()
+
+	c.JSON(http.StatusOK, models.APIKeyListResponse{
+		Keys:  keys,
+		Count: len(keys),
+	})
+}
+
+// @Summary Revoke an API key
+// @Description Revoke an API key. Revoked keys stay listed but are rejected
+// @Tags api-keys
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} models.APIKeyInfo
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/keys/{id} [delete]
+func (h *APIKeysHandler) RevokeAPIKey(c *gin.Context) {
+	info, err := h.store.Revoke(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "Not Found",
+				Message: "API key not found",
+				Code:    http.StatusNotFound,
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/internal/services"
+)
+
+type CredentialsHandler struct {
+	store  *services.CredentialStore
+	logger *logrus.Logger
+}
+
+func NewCredentialsHandler(store *services.CredentialStore, logger *logrus.Logger) *CredentialsHandler {
+	return &CredentialsHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// @Summary Register a service account
+// @Description Store a service account on the server and get an opaque credential ID to use instead of sending the private key with every request
+// @Tags credentials
+// @Accept json
+// @Produce json
+// @Param request body models.CredentialRequest true "Service account to register, with optional allowed hosts or URL prefixes"
+// @Success 201 {object} models.CredentialInfo
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/credentials [post]
+func (h *CredentialsHandler) RegisterCredential(c *gin.Context) {
+	var req models.CredentialRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid request format",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := validateServiceAccount(req.ServiceAccount); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: fmt.Sprintf("Invalid service account: %v", err),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	info, err := h.store.Register(req.Name, req.ServiceAccount, req.AllowedURLs)
+	if errors.Is(err, services.ErrInvalidAllowedURLs) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to register credential")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to register credential",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, info)
+}
+
+// @Summary List registered service accounts
+// @Description List registered service accounts without their private keys
+// @Tags credentials
+// @Produce json
+// @Success 200 {object} models.CredentialListResponse
+// @Router /api/v1/credentials [get]
+func (h *CredentialsHandler) ListCredentials(c *gin.Context) {
+	credentials := h.store.List()
+
+	c.JSON(http.StatusOK, models.CredentialListResponse{
+		Credentials: credentials,
+		Count:       len(credentials),
+	})
+}
+
+// @Summary Get a registered service account
+// @Description Get a registered service account without its private key
+// @Tags credentials
+// @Produce json
+// @Param id path string true "Credential ID"
+// @Success 200 {object} models.CredentialInfo
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/credentials/{id} [get]
+func (h *CredentialsHandler) GetCredential(c *gin.Context) {
+	info, err := h.store.Get(c.Param("id"))
+	if err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// @Summary Rotate a registered service account key
+// @Description Replace the key of a registered service account. The new key must belong to the same client_email
+// @Tags credentials
+// @Accept json
+// @Produce json
+// @Param id path string true "Credential ID"
+// @Param request body models.CredentialRequest true "Service account with the new key"
+// @Success 200 {object} models.CredentialInfo
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/credentials/{id}/rotate [post]
+func (h *CredentialsHandler) RotateCredential(c *gin.Context) {
+	var req models.CredentialRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid request format",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := validateServiceAccount(req.ServiceAccount); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: fmt.Sprintf("Invalid service account: %v", err),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	info, err := h.store.Rotate(c.Param("id"), req.Name, req.ServiceAccount)
+	if err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// @Summary Set the allowed URLs of a registered service account
+// @Description Replace the hosts and URL prefixes a registered service account may submit. URLs outside them are rejected with error kind not_owned before reaching Google. An empty list allows every URL
+// @Tags credentials
+// @Accept json
+// @Produce json
+// @Param id path string true "Credential ID"
+// @Param request body models.AllowedURLsRequest true "Allowed hosts and URL prefixes"
+// @Success 200 {object} models.CredentialInfo
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/credentials/{id}/allowed-urls [put]
+func (h *CredentialsHandler) SetAllowedURLs(c *gin.Context) {
+	var req models.AllowedURLsRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid request format",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	info, err := h.store.SetAllowedURLs(c.Param("id"), req.AllowedURLs)
+	if err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// @Summary Delete a registered service account
+// @Description Remove a registered service account from the server
+// @Tags credentials
+// @Produce json
+// @Param id path string true "Credential ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/credentials/{id} [delete]
+func (h *CredentialsHandler) DeleteCredential(c *gin.Context) {
+	if err := h.store.Delete(c.Param("id")); err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Credential deleted successfully",
+	})
+}
+
+// @Summary Rotate the master key
+// @Description Reload the master key file and re-wrap every stored private key with the new primary key. Keys that cannot decrypt every stored key are refused. Keys set through MASTER_KEY are only read on startup
+// @Tags credentials
+// @Produce json
+// @Success 200 {object} models.MasterKeyRotationResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/master-key/rotate [post]
+func (h *CredentialsHandler) RotateMasterKey(c *gin.Context) {
+	response, err := h.store.RotateMasterKey()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to rotate master key")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: fmt.Sprintf("Failed to rotate master key: %v", err),
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// respondStoreError maps credential store errors to responses. Only not found
+// and validation errors are reported to the client as they are.
+func (h *CredentialsHandler) respondStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCredentialNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Credential not found",
+			Code:    http.StatusNotFound,
+		})
+	case errors.Is(err, services.ErrClientEmailMismatch), errors.Is(err, services.ErrInvalidAllowedURLs):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+	default:
+		h.logger.WithError(err).WithField("credential_id", c.Param("id")).Error("Failed to update credential")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to update credential",
+			Code:    http.StatusInternalServerError,
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/internal/store"
+)
+
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+var historyCSVHeader = []string{
+	"id", "notify_time", "url", "type", "service_account", "project_id", "job_id",
+	"success", "attempts", "error_kind", "error", "google_response",
+}
+
+type HistoryHandler struct {
+	history store.Store
+	logger  *logrus.Logger
+}
+
+// NewHistoryHandler creates the history handler. history may be nil when no
+// store is configured, in which case the endpoint answers 503.
+func NewHistoryHandler(history store.Store, logger *logrus.Logger) *HistoryHandler {
+	return &HistoryHandler{
+		history: history,
+		logger:  logger,
+	}
+}
+
+// @Summary Query submission history
+// @Description List notifications sent to Google, newest first. Every filter is optional. JSON responses return next_cursor in the body; CSV and NDJSON exports return it in the X-Next-Cursor header, and stream every matching record when no limit is given
+// @Tags history
+// @Produce json,text/csv,application/x-ndjson
+// @Param url_prefix query string false "Only URLs starting with this prefix"
+// @Param host query string false "Only URLs on this host"
+// @Param service_account query string false "Only notifications sent with this client email"
+// @Param type query string false "URL_UPDATED or URL_DELETED"
+// @Param success query bool false "Only successful or failed notifications"
+// @Param since query string false "RFC 3339 time, inclusive"
+// @Param until query string false "RFC 3339 time, exclusive"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Page size, 1 to 1000 (default 100)"
+// @Param format query string false "json (default), csv or ndjson"
+// @Success 200 {object} models.HistoryResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /api/v1/history [get]
+func (h *HistoryHandler) GetHistory(c *gin.Context) {
+	if h.history == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "Service Unavailable",
+			Message: "Submission history is disabled; set STORE_PATH to enable it",
+			Code:    http.StatusServiceUnavailable,
+		})
+		return
+	}
+
+	query, err := parseHistoryQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	switch format {
+	case "json":
+		records, more, err := h.history.ListNotifications(c.Request.Context(), query)
+		if err != nil {
+			h.respondHistoryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.HistoryResponse{
+			Records:    records,
+			Count:      len(records),
+			NextCursor: nextHistoryCursor(records, more),
+		})
+	case "csv", "ndjson":
+		h.exportHistory(c, query, format, c.Query("limit") == "")
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "format must be json, csv or ndjson",
+			Code:    http.StatusBadRequest,
+		})
+	}
+}
+
+// exportHistory writes matching records as CSV or NDJSON. With all set, it
+// keeps fetching pages until every record has been written; otherwise it
+// writes one page and returns the cursor in the X-Next-Cursor header.
+func (h *HistoryHandler) exportHistory(c *gin.Context, query store.NotificationQuery, format string, all bool) {
+	ctx := c.Request.Context()
+	if all {
+		query.Limit = maxHistoryLimit
+	}
+
+	// The first page is read before writing anything, so a store error can
+	// still be answered with an error status
+	records, more, err := h.history.ListNotifications(ctx, query)
+	if err != nil {
+		h.respondHistoryError(c, err)
+		return
+	}
+
+	if !all {
+		if cursor := nextHistoryCursor(records, more); cursor != "" {
+			c.Header("X-Next-Cursor", cursor)
+		}
+	}
+
+	var write func(record *models.NotificationRecord) error
+	var flush func() error
+
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="history.csv"`)
+		c.Status(http.StatusOK)
+
+		writer := csv.NewWriter(c.Writer)
+		if err := writer.Write(historyCSVHeader); err != nil {
+			return
+		}
+		write = func(record *models.NotificationRecord) error {
+			return writer.Write(historyCSVRow(record))
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+
+		encoder := json.NewEncoder(c.Writer)
+		write = func(record *models.NotificationRecord) error {
+			return encoder.Encode(record)
+		}
+		flush = func() error {
+			c.Writer.Flush()
+			return nil
+		}
+	}
+
+	for {
+		for i := range records {
+			if err := write(&records[i]); err != nil {
+				return
+			}
+		}
+		if err := flush(); err != nil {
+			return
+		}
+
+		if !all || !more {
+			return
+		}
+
+		query.Before = records[len(records)-1].ID
+		records, more, err = h.history.ListNotifications(ctx, query)
+		if err != nil {
+			// Headers are already sent; the truncated export is all we can do
+			h.logger.WithError(err).Error("Failed to read submission history during export")
+			return
+		}
+	}
+}
+
+func (h *HistoryHandler) respondHistoryError(c *gin.Context, err error) {
+	h.logger.WithError(err).Error("Failed to read submission history")
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error:   "Internal Server Error",
+		Message: "Failed to read submission history",
+		Code:    http.StatusInternalServerError,
+	})
+}
+
+func parseHistoryQuery(c *gin.Context) (store.NotificationQuery, error) {
+	query := store.NotificationQuery{
+		URLPrefix:      c.Query("url_prefix"),
+		Host:           c.Query("host"),
+		ServiceAccount: c.Query("service_account"),
+		Type:           c.Query("type"),
+		Limit:          defaultHistoryLimit,
+	}
+
+	if query.Type != "" && query.Type != "URL_UPDATED" && query.Type != "URL_DELETED" {
+		return query, errors.New("type must be URL_UPDATED or URL_DELETED")
+	}
+
+	if value := c.Query("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, errors.New("success must be true or false")
+		}
+		query.Success = &success
+	}
+
+	for name, target := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := c.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return query, errors.New(name + " must be an RFC 3339 time, e.g. 2024-01-02T15:04:05Z")
+			}
+			*target = parsed
+		}
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(raw) != 8 {
+			return query, errors.New("invalid cursor")
+		}
+		query.Before = binary.BigEndian.Uint64(raw)
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxHistoryLimit {
+			return query, errors.New("limit must be between 1 and 1000")
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+// nextHistoryCursor returns the opaque cursor of the page after records, or
+// an empty string on the last page.
+func nextHistoryCursor(records []models.NotificationRecord, more bool) string {
+	if !more || len(records) == 0 {
+		return ""
+	}
+
+	raw := make([]byte, 8)
+	binary.BigEndian.PutUint64(raw, records[len(records)-1].ID)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func historyCSVRow(record *models.NotificationRecord) []string {
+	return []string{
+		strconv.FormatUint(record.ID, 10),
+		record.NotifyTime,
+		record.URL,
+		record.Type,
+		record.ServiceAccount,
+		record.ProjectID,
+		record.JobID,
+		strconv.FormatBool(record.Success),
+		strconv.Itoa(record.Attempts),
+		record.ErrorKind,
+		record.Error,
+		string(record.GoogleResponse),
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"google-indexing-api/internal/config"
+	"google-indexing-api/internal/middleware"
+	"google-indexing-api/internal/models"
+	"google-indexing-api/internal/services"
+)
+
+// Asynchronous batch jobs. They share request validation with
+// SubmitURLsBatch, so they are served by IndexingHandler.
+
+// @Summary Submit a batch job
+// @Description Queue a batch of URLs for submission in the background and return a job ID to poll
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param request body models.BatchIndexRequest true "URLs to index with service account"
+// @Success 202 {object} models.JobResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/jobs [post]
+func (h *IndexingHandler) SubmitJob(c *gin.Context) {
+	req, accounts, ok := h.bindBatchRequest(c, config.GetConfig().Jobs.MaxURLs)
+	if !ok {
+		return
+	}
+
+	apiKeyID := ""
+	if key, ok := middleware.CurrentAPIKey(c); ok {
+		apiKeyID = key.ID
+	}
+
+	job, err := h.jobs.Submit(req.URLs, accounts, apiKeyID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to queue batch job")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to queue batch job",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// @Summary Get a batch job
+// @Description Get the progress of a batch job and the results of the URLs processed so far
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.JobResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/jobs/{id} [get]
+func (h *IndexingHandler) GetJob(c *gin.Context) {
+	job, err := h.jobs.Get(c.Param("id"))
+	if err != nil {
+		h.respondJobError(c, err)
+		return
+	}
+	if !canAccessJob(c, job) {
+		h.respondJobNotFound(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary Cancel a batch job
+// @Description Stop submitting the remaining URLs of a batch job. URLs already sent to Google are not affected
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.JobResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/jobs/{id} [delete]
+func (h *IndexingHandler) CancelJob(c *gin.Context) {
+	job, err := h.jobs.Get(c.Param("id"))
+	if err != nil {
+		h.respondJobError(c, err)
+		return
+	}
+	if !canAccessJob(c, job) {
+		h.respondJobNotFound(c)
+		return
+	}
+
+	job, err = h.jobs.Cancel(job.ID)
+	if err != nil {
+		h.respondJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// respondJobError answers 404 for unknown jobs and 500 when the job store
+// failed.
+func (h *IndexingHandler) respondJobError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrJobNotFound) {
+		h.respondJobNotFound(c)
+		return
+	}
+
+	h.logger.WithError(err).WithField("job_id", c.Param("id")).Error("Failed to read batch job")
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error:   "Internal Server Error",
+		Message: "Failed to read batch job",
+		Code:    http.StatusInternalServerError,
+	})
+}
+
+func (h *IndexingHandler) respondJobNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error:   "Not Found",
+		Message: services.ErrJobNotFound.Error(),
+		Code:    http.StatusNotFound,
+	})
+}
+
+// canAccessJob reports whether the caller created the job or is an admin.
+// Other callers get a 404, so job IDs cannot be probed.
+func canAccessJob(c *gin.Context, job *models.JobResponse) bool {
+	key, ok := middleware.CurrentAPIKey(c)
+	if !ok {
+		return job.APIKeyID == ""
+	}
+
+	return job.APIKeyID == key.ID || slices.Contains(key.Roles, models.RoleAdmin)
+}
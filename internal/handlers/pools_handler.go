@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/internal/services"
+)
+
+type PoolsHandler struct {
+	store  *services.PoolStore
+	logger *logrus.Logger
+}
+
+func NewPoolsHandler(store *services.PoolStore, logger *logrus.Logger) *PoolsHandler {
+	return &PoolsHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// @Summary Create a credential pool
+// @Description Group registered service accounts under a name that submissions can use instead of a single account
+// @Tags pools
+// @Accept json
+// @Produce json
+// @Param request body models.PoolRequest true "Pool name, credential IDs and strategy"
+// @Success 201 {object} models.PoolInfo
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/pools [post]
+func (h *PoolsHandler) CreatePool(c *gin.Context) {
+	req, ok := h.bindPoolRequest(c)
+	if !ok {
+		return
+	}
+
+	info, err := h.store.Create(req.Name, req.CredentialIDs, req.Strategy)
+	if err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, info)
+}
+
+// @Summary List credential pools
+// @Description List credential pools and their members
+// @Tags pools
+// @Produce json
+// @Success 200 {object} models.PoolListResponse
+// @Router /api/v1/pools [get]
+func (h *PoolsHandler) ListPools(c *gin.Context) {
+	pools := h.store.List()
+
+	c.JSON(http.StatusOK, models.PoolListResponse{
+		Pools: pools,
+		Count: len(pools),
+	})
+}
+
+// @Summary Get a credential pool
+// @Description Get a credential pool and its members
+// @Tags pools
+// @Produce json
+// @Param name path string true "Pool name"
+// @Success 200 {object} models.PoolInfo
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/pools/{name} [get]
+func (h *PoolsHandler) GetPool(c *gin.Context) {
+	info, err := h.store.Get(c.Param("name"))
+	if err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// @Summary Update a credential pool
+// @Description Replace the members and strategy of a credential pool
+// @Tags pools
+// @Accept json
+// @Produce json
+// @Param name path string true "Pool name"
+// @Param request body models.PoolRequest true "Credential IDs and strategy"
+// @Success 200 {object} models.PoolInfo
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/pools/{name} [put]
+func (h *PoolsHandler) UpdatePool(c *gin.Context) {
+	req, ok := h.bindPoolRequest(c)
+	if !ok {
+		return
+	}
+
+	info, err := h.store.Update(c.Param("name"), req.CredentialIDs, req.Strategy)
+	if err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// @Summary Delete a credential pool
+// @Description Remove a credential pool. Its credentials stay registered
+// @Tags pools
+// @Produce json
+// @Param name path string true "Pool name"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/pools/{name} [delete]
+func (h *PoolsHandler) DeletePool(c *gin.Context) {
+	if err := h.store.Delete(c.Param("name")); err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Pool deleted successfully",
+	})
+}
+
+func (h *PoolsHandler) bindPoolRequest(c *gin.Context) (*models.PoolRequest, bool) {
+	var req models.PoolRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid request format: credential_ids is required and strategy must be round_robin or least_used",
+			Code:    http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	return &req, true
+}
+
+func (h *PoolsHandler) respondStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPoolNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Pool not found",
+			Code:    http.StatusNotFound,
+		})
+	case errors.Is(err, services.ErrPoolExists):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: "A pool with this name already exists",
+			Code:    http.StatusConflict,
+		})
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+	}
+}
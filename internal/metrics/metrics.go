@@ -0,0 +1,44 @@
+// Package metrics defines the Prometheus metrics exported on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "google_indexing"
+
+var (
+	// HTTPRequests counts handled HTTP requests by method, route template and
+	// status code.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes request latency by method and route template.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method and route.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method", "route"})
+
+	// GoogleAPICalls counts calls to Google by API method (publish,
+	// getMetadata, batch) and result code. The code is the HTTP status, or the
+	// error kind when no response was received. Every part of a batch call is
+	// also counted as a publish call.
+	GoogleAPICalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "google_api_calls_total",
+		Help:      "Google API calls, by API method and result code.",
+	}, []string{"method", "code"})
+
+	// GoogleAPIRetries counts retried Google API calls by API method.
+	GoogleAPIRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "google_api_retries_total",
+		Help:      "Google API calls retried after a transient failure, by API method.",
+	}, []string{"method"})
+)
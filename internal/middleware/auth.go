@@ -1,31 +1,126 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 
 	"google-indexing-api/internal/models"
+	"google-indexing-api/internal/services"
 )
 
-func CORS() gin.HandlerFunc {
+// apiKeyContextKey is the gin context key holding the authenticated
+// *models.APIKeyInfo.
+const apiKeyContextKey = "api_key"
+
+// APIKeyAuth rejects requests that do not carry a valid API key, either as
+// "Authorization: Bearer <key>" or in the X-API-Key header.
+func APIKeyAuth(store *services.APIKeyStore, logger *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Simple CORS - allow all origins
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		c.Header("Access-Control-Allow-Credentials", "true")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
+		plain := apiKeyFromRequest(c)
+		if plain == "" {
+			abortUnauthorized(c, "API key required", "missing_api_key")
 			return
 		}
 
+		key, err := store.Authenticate(plain)
+		if err != nil {
+			logger.WithFields(logrus.Fields{
+				"ip":   c.ClientIP(),
+				"path": c.Request.URL.Path,
+			}).WithError(err).Warn("Rejected API key")
+
+			switch {
+			case errors.Is(err, services.ErrAPIKeyExpired):
+				abortUnauthorized(c, "API key has expired", "expired_api_key")
+			case errors.Is(err, services.ErrAPIKeyRevoked):
+				abortUnauthorized(c, "API key has been revoked", "revoked_api_key")
+			default:
+				abortUnauthorized(c, "Invalid API key", "invalid_api_key")
+			}
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
 		c.Next()
 	}
 }
 
+// RequireRole rejects requests whose API key has none of roles. Admin keys are
+// always allowed. It must run after APIKeyAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	allowed := append([]string{}, roles...)
+	if !slices.Contains(allowed, models.RoleAdmin) {
+		allowed = append(allowed, models.RoleAdmin)
+	}
+	message := fmt.Sprintf("This endpoint requires one of the roles: %s", strings.Join(allowed, ", "))
+
+	return func(c *gin.Context) {
+		key, ok := CurrentAPIKey(c)
+		if !ok {
+			abortUnauthorized(c, "API key required", "missing_api_key")
+			return
+		}
+
+		for _, granted := range key.Roles {
+			if slices.Contains(allowed, granted) {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: message,
+			Code:    http.StatusForbidden,
+			Reason:  "insufficient_role",
+		})
+	}
+}
+
+// CurrentAPIKey returns the API key the request was authenticated with.
+func CurrentAPIKey(c *gin.Context) (*models.APIKeyInfo, bool) {
+	value, exists := c.Get(apiKeyContextKey)
+	if !exists {
+		return nil, false
+	}
+
+	key, ok := value.(*models.APIKeyInfo)
+	return key, ok
+}
+
+// apiKeyFromRequest returns the API key sent with the request, or "".
+func apiKeyFromRequest(c *gin.Context) string {
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+		return apiKey
+	}
+
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	return ""
+}
+
+func abortUnauthorized(c *gin.Context, message string, reason string) {
+	if abortFailedAuthLimited(c) {
+		return
+	}
+
+	c.Header("WWW-Authenticate", `Bearer realm="api"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{
+		Error:   "Unauthorized",
+		Message: message,
+		Code:    http.StatusUnauthorized,
+		Reason:  reason,
+	})
+}
+
 func RequestLogger(logger *logrus.Logger) gin.HandlerFunc {
 	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
 		logger.WithFields(logrus.Fields{
@@ -36,7 +131,7 @@ func RequestLogger(logger *logrus.Logger) gin.HandlerFunc {
 			"user_agent": param.Request.UserAgent(),
 			"latency":    param.Latency,
 		}).Info("HTTP Request")
-		
+
 		return ""
 	})
 }
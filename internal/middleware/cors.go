@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"google-indexing-api/internal/config"
+)
+
+// CORS answers cross-origin requests according to config.CORS. Only origins
+// matching CORS_ALLOWED_ORIGINS are echoed back. Entries are either exact
+// origins ("https://example.com"), wildcard subdomains ("https://*.example.com"
+// or "*.example.com" for any scheme), or "*" for every origin. Credentials are
+// only allowed for listed origins, never with "*".
+func CORS() gin.HandlerFunc {
+	cfg := config.GetConfig().CORS
+
+	var patterns []originPattern
+	allowAll := false
+	for _, entry := range cfg.AllowedOrigins {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch entry {
+		case "":
+		case "*":
+			allowAll = true
+		default:
+			patterns = append(patterns, parseOriginPattern(entry))
+		}
+	}
+
+	allowMethods := joinHeaderList(cfg.AllowedMethods)
+	allowHeaders := joinHeaderList(cfg.AllowedHeaders)
+	exposeHeaders := joinHeaderList(cfg.ExposedHeaders)
+	maxAge := strconv.Itoa(cfg.MaxAgeSeconds)
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		// Unless every origin is allowed, the response depends on Origin even
+		// when none was sent, so shared caches must not reuse it across origins
+		if !allowAll {
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !allowAll && !matchOrigin(patterns, origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// Credentials are never allowed for every origin; config rejects
+		// the combination, so "*" only guards against a hand-built config
+		if allowAll {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+			if cfg.AllowCredentials {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", allowMethods)
+			c.Header("Access-Control-Allow-Headers", allowHeaders)
+			if cfg.MaxAgeSeconds > 0 {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if exposeHeaders != "" {
+			c.Header("Access-Control-Expose-Headers", exposeHeaders)
+		}
+
+		c.Next()
+	}
+}
+
+// originPattern is a parsed CORS_ALLOWED_ORIGINS entry. An empty scheme
+// matches any scheme; a host starting with "*." matches any subdomain.
+type originPattern struct {
+	scheme string
+	host   string
+}
+
+func parseOriginPattern(entry string) originPattern {
+	if scheme, host, found := strings.Cut(entry, "://"); found {
+		return originPattern{scheme: scheme, host: strings.TrimSuffix(host, "/")}
+	}
+
+	return originPattern{host: strings.TrimSuffix(entry, "/")}
+}
+
+func matchOrigin(patterns []originPattern, origin string) bool {
+	parsed, err := url.Parse(strings.ToLower(origin))
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		if pattern.scheme != "" && pattern.scheme != parsed.Scheme {
+			continue
+		}
+
+		if suffix, wildcard := strings.CutPrefix(pattern.host, "*"); wildcard {
+			if strings.HasSuffix(parsed.Host, suffix) && len(parsed.Host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+
+		if pattern.host == parsed.Host {
+			return true
+		}
+	}
+
+	return false
+}
+
+func joinHeaderList(values []string) string {
+	trimmed := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+
+	return strings.Join(trimmed, ", ")
+}
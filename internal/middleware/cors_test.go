@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"google-indexing-api/internal/config"
+)
+
+func TestCORSVaryOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	previous := config.AppConfig
+	defer func() { config.AppConfig = previous }()
+
+	tests := []struct {
+		origins []string
+		origin  string
+		vary    bool
+	}{
+		{[]string{"https://app.example.com"}, "", true},
+		{[]string{"https://app.example.com"}, "https://app.example.com", true},
+		{[]string{"https://app.example.com"}, "https://evil.test", true},
+		{[]string{"*"}, "", false},
+		{[]string{"*"}, "https://evil.test", false},
+	}
+
+	for _, tt := range tests {
+		config.AppConfig = &config.Config{}
+		config.AppConfig.CORS.AllowedOrigins = tt.origins
+
+		router := gin.New()
+		router.Use(CORS())
+		router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if vary := w.Header().Get("Vary") == "Origin"; vary != tt.vary {
+			t.Errorf("origins %v, Origin %q: got Vary %q, want Origin: %v", tt.origins, tt.origin, w.Header().Get("Vary"), tt.vary)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/pkg/utils"
+)
+
+// rateLimitSweepInterval is how often idle client buckets are dropped.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimiter keeps one token bucket per client. A client is identified by the
+// API key it authenticated with, see rateLimitKey.
+type RateLimiter struct {
+	perMinute int
+	buckets   map[string]*utils.TokenBucket
+	mutex     sync.Mutex
+	lastSweep time.Time
+}
+
+func NewRateLimiter(perMinute int) *RateLimiter {
+	return &RateLimiter{
+		perMinute: perMinute,
+		buckets:   make(map[string]*utils.TokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// RateLimit returns a middleware allowing each client perMinute requests per
+// minute. Each call creates an independent limiter, so route groups can use
+// different limits. A non-positive limit disables rate limiting. It must run
+// after APIKeyAuth; requests that fail authentication are limited by
+// FailedAuthLimit instead.
+func RateLimit(perMinute int) gin.HandlerFunc {
+	return NewRateLimiter(perMinute).Middleware()
+}
+
+func (rl *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rl.perMinute <= 0 {
+			c.Next()
+			return
+		}
+
+		bucket := rl.bucket(rateLimitKey(c))
+		allowed, remaining, wait := bucket.Take()
+		reset := time.Now().Add(bucket.UntilFull())
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(rl.perMinute))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, models.ErrorResponse{
+				Error:   "Too Many Requests",
+				Message: fmt.Sprintf("Rate limit exceeded, retry in %d seconds", retryAfter),
+				Code:    http.StatusTooManyRequests,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (rl *RateLimiter) bucket(key string) *utils.TokenBucket {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(rl.lastSweep) >= rateLimitSweepInterval {
+		rl.sweep(now)
+	}
+
+	bucket, exists := rl.buckets[key]
+	if !exists {
+		bucket = utils.NewTokenBucket(rl.perMinute, float64(rl.perMinute)/60)
+		rl.buckets[key] = bucket
+	}
+
+	return bucket
+}
+
+// sweep drops buckets that have been idle long enough to be full again, since
+// recreating them is equivalent. Callers must hold rl.mutex.
+func (rl *RateLimiter) sweep(now time.Time) {
+	for key, bucket := range rl.buckets {
+		if now.Sub(bucket.LastUsed()) > time.Minute {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastSweep = now
+}
+
+// rateLimitKey identifies the client of a request by the ID of its API key,
+// or by its IP address when it was not authenticated.
+func rateLimitKey(c *gin.Context) string {
+	if key, ok := CurrentAPIKey(c); ok {
+		return "key:" + key.ID
+	}
+
+	return "ip:" + c.ClientIP()
+}
+
+// failedAuthContextKey holds the failed authentication bucket of the client
+// IP, set by FailedAuthLimit.
+const failedAuthContextKey = "failed_auth_bucket"
+
+// FailedAuthLimit returns a middleware allowing each IP address perMinute
+// failed authentications per minute, so keys cannot be guessed at full speed.
+// Once they are used up, further failures from the address get 429 instead of
+// 401. Requests with a valid key are never blocked, so one client behind a
+// shared NAT or proxy cannot lock out the others. It must run before
+// APIKeyAuth. A non-positive limit disables it.
+func FailedAuthLimit(perMinute int) gin.HandlerFunc {
+	rl := NewRateLimiter(perMinute)
+
+	return func(c *gin.Context) {
+		if rl.perMinute > 0 {
+			c.Set(failedAuthContextKey, rl.bucket("ip:"+c.ClientIP()))
+		}
+		c.Next()
+	}
+}
+
+// abortFailedAuthLimited counts a failed authentication against the client IP
+// and answers 429 when the IP has none left. It reports whether it did.
+func abortFailedAuthLimited(c *gin.Context) bool {
+	value, exists := c.Get(failedAuthContextKey)
+	if !exists {
+		return false
+	}
+
+	allowed, _, wait := value.(*utils.TokenBucket).Take()
+	if allowed {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, models.ErrorResponse{
+		Error:   "Too Many Requests",
+		Message: fmt.Sprintf("Too many failed authentications, retry in %d seconds", retryAfter),
+		Code:    http.StatusTooManyRequests,
+		Reason:  "too_many_auth_failures",
+	})
+
+	return true
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFailedAuthLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(FailedAuthLimit(3))
+	router.GET("/", func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") != "valid" {
+			abortUnauthorized(c, "Invalid API key", "invalid_api_key")
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	request := func(apiKey string, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Successful authentications are not counted
+	for i := 0; i < 5; i++ {
+		if w := request("valid", "10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("valid key got %d, want 200", w.Code)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := request("guess", "10.0.0.1:1234"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("guess %d got %d, want 401", i, w.Code)
+		}
+	}
+
+	w := request("guess", "10.0.0.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got %d after the failures were used up, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 response has no Retry-After header")
+	}
+
+	// Other clients behind the same address keep working
+	if w := request("valid", "10.0.0.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("valid key from a blocked address got %d, want 200", w.Code)
+	}
+
+	if w := request("guess", "10.0.0.2:1234"); w.Code != http.StatusUnauthorized {
+		t.Errorf("other address got %d, want 401", w.Code)
+	}
+}
@@ -1,5 +1,23 @@
 package models
 
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// Notification types accepted by the Google Indexing API.
+const (
+	NotificationTypeUpdated = "URL_UPDATED"
+	NotificationTypeDeleted = "URL_DELETED"
+)
+
+// IsValidNotificationType reports whether t is one of the notification types
+// supported by the Google Indexing API.
+func IsValidNotificationType(t string) bool {
+	return t == NotificationTypeUpdated || t == NotificationTypeDeleted
+}
+
 type ServiceAccountCredentials struct {
 	Type                    string `json:"type"`
 	ProjectID               string `json:"project_id"`
@@ -13,20 +31,73 @@ type ServiceAccountCredentials struct {
 	ClientX509CertURL       string `json:"client_x509_cert_url"`
 }
 
+// IndexRequest and the other request types below take either a registered
+// credential_id or an inline service_account, but not both. Submissions can
+// also name a pool of registered credentials instead.
 type IndexRequest struct {
 	URL            string                     `json:"url" validate:"required,url" binding:"required"`
-	ServiceAccount *ServiceAccountCredentials `json:"service_account" validate:"required" binding:"required"`
+	Type           string                     `json:"type,omitempty" validate:"omitempty,oneof=URL_UPDATED URL_DELETED" binding:"omitempty,oneof=URL_UPDATED URL_DELETED"`
+	CredentialID   string                     `json:"credential_id,omitempty"`
+	ServiceAccount *ServiceAccountCredentials `json:"service_account,omitempty"`
+	Pool           string                     `json:"pool,omitempty"`
 }
 
 type BatchIndexRequest struct {
-	URLs           []string                   `json:"urls" validate:"required,min=1,dive,url" binding:"required"`
-	ServiceAccount *ServiceAccountCredentials `json:"service_account" validate:"required" binding:"required"`
+	URLs           []BatchIndexItem           `json:"urls" validate:"required,min=1,dive" binding:"required,min=1,dive"`
+	Type           string                     `json:"type,omitempty" validate:"omitempty,oneof=URL_UPDATED URL_DELETED" binding:"omitempty,oneof=URL_UPDATED URL_DELETED"`
+	CredentialID   string                     `json:"credential_id,omitempty"`
+	ServiceAccount *ServiceAccountCredentials `json:"service_account,omitempty"`
+	Pool           string                     `json:"pool,omitempty"`
+}
+
+// BatchIndexItem is a single URL in a batch request. It can be given either as
+// a plain URL string, which uses the batch-level type, or as an object with its
+// own notification type.
+type BatchIndexItem struct {
+	URL  string `json:"url" validate:"required,url" binding:"required"`
+	Type string `json:"type,omitempty" validate:"omitempty,oneof=URL_UPDATED URL_DELETED" binding:"omitempty,oneof=URL_UPDATED URL_DELETED"`
+}
+
+func (i *BatchIndexItem) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '"' {
+		i.Type = ""
+		return json.Unmarshal(trimmed, &i.URL)
+	}
+
+	type item BatchIndexItem
+	return json.Unmarshal(data, (*item)(i))
 }
 
+// Error kinds reported for failed calls, so clients can tell failure modes
+// apart without parsing messages.
+const (
+	ErrorKindTimeout     = "timeout"
+	ErrorKindCanceled    = "canceled"
+	ErrorKindGoogleAPI   = "google_api_error"
+	ErrorKindNetwork     = "network_error"
+	ErrorKindCredentials = "invalid_credentials"
+	ErrorKindInternal    = "internal_error"
+
+	// ErrorKindQuotaExhausted is a 429 RESOURCE_EXHAUSTED from Google.
+	ErrorKindQuotaExhausted = "quota_exhausted"
+
+	// ErrorKindNotOwned is a URL outside the allowed hosts and URL prefixes
+	// of the service account. It is never sent to Google.
+	ErrorKindNotOwned = "not_owned"
+)
+
+// IndexResponse is the outcome of one notification. ServiceAccount is the
+// client email the notification was sent with, which matters for pools.
 type IndexResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	URL     string `json:"url,omitempty"`
+	Success        bool    `json:"success"`
+	Message        string  `json:"message"`
+	URL            string  `json:"url,omitempty"`
+	Type           string  `json:"type,omitempty"`
+	ServiceAccount string  `json:"service_account,omitempty"`
+	Attempts       int     `json:"attempts"`
+	ErrorKind      string  `json:"error_kind,omitempty"`
+	Timing         *Timing `json:"timing,omitempty"`
 }
 
 type BatchIndexResponse struct {
@@ -34,6 +105,15 @@ type BatchIndexResponse struct {
 	Message    string                  `json:"message"`
 	Results    []IndexResponse         `json:"results,omitempty"`
 	Statistics BatchIndexResponseStats `json:"statistics,omitempty"`
+	Timing     *Timing                 `json:"timing,omitempty"`
+}
+
+// Timing reports where the time of a submission went. QueueWaitMs is the time
+// spent waiting for the outbound rate limit toward Google; for a batch it is
+// the longest wait of any of its URLs.
+type Timing struct {
+	QueueWaitMs int64 `json:"queue_wait_ms"`
+	TotalMs     int64 `json:"total_ms"`
 }
 
 type BatchIndexResponseStats struct {
@@ -42,10 +122,276 @@ type BatchIndexResponseStats struct {
 	Failed     int `json:"failed"`
 }
 
+// Status values reported in StatusResponse besides the notification types.
+const (
+	StatusNotNotified = "NOT_NOTIFIED"
+	StatusError       = "error"
+)
+
+type StatusRequest struct {
+	URL            string                     `json:"url" validate:"required,url" binding:"required"`
+	CredentialID   string                     `json:"credential_id,omitempty"`
+	ServiceAccount *ServiceAccountCredentials `json:"service_account,omitempty"`
+}
+
+// NotificationInfo is the latest notification of one type that Google has
+// received for a URL.
+type NotificationInfo struct {
+	URL        string `json:"url"`
+	Type       string `json:"type"`
+	NotifyTime string `json:"notify_time"`
+}
+
+// StatusResponse mirrors Google's UrlNotificationMetadata. Status and
+// LastUpdated describe the most recent of the two notifications.
 type StatusResponse struct {
-	URL         string `json:"url"`
-	Status      string `json:"status"`
-	LastUpdated string `json:"last_updated,omitempty"`
+	URL          string            `json:"url"`
+	Status       string            `json:"status"`
+	LastUpdated  string            `json:"last_updated,omitempty"`
+	LatestUpdate *NotificationInfo `json:"latest_update,omitempty"`
+	LatestRemove *NotificationInfo `json:"latest_remove,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	ErrorKind    string            `json:"error_kind,omitempty"`
+}
+
+type BatchStatusRequest struct {
+	URLs           []string                   `json:"urls" validate:"required,min=1,dive,url" binding:"required,min=1"`
+	CredentialID   string                     `json:"credential_id,omitempty"`
+	ServiceAccount *ServiceAccountCredentials `json:"service_account,omitempty"`
+}
+
+type BatchStatusResponse struct {
+	Success    bool                     `json:"success"`
+	Message    string                   `json:"message"`
+	Results    []StatusResponse         `json:"results,omitempty"`
+	Statistics BatchStatusResponseStats `json:"statistics"`
+}
+
+type BatchStatusResponseStats struct {
+	Total         int `json:"total"`
+	NeverNotified int `json:"never_notified"`
+	Updated       int `json:"updated"`
+	Removed       int `json:"removed"`
+	Errored       int `json:"errored"`
+}
+
+type CacheStats struct {
+	CachedServices int               `json:"cached_services"`
+	HasDefault     bool              `json:"has_default"`
+	MaxEntries     int               `json:"max_entries"`
+	TTLSeconds     int64             `json:"ttl_seconds"`
+	Hits           uint64            `json:"hits"`
+	Misses         uint64            `json:"misses"`
+	Evictions      uint64            `json:"evictions"`
+	Expirations    uint64            `json:"expirations"`
+	Replacements   uint64            `json:"replacements"`
+	Entries        []CacheEntryStats `json:"entries"`
+	Timestamp      string            `json:"timestamp"`
+}
+
+type CacheEntryStats struct {
+	ServiceAccount   string `json:"service_account"`
+	Fingerprint      string `json:"fingerprint"`
+	CreatedAt        string `json:"created_at"`
+	LastUsedAt       string `json:"last_used_at"`
+	AgeSeconds       int64  `json:"age_seconds"`
+	ExpiresInSeconds int64  `json:"expires_in_seconds,omitempty"`
+}
+
+// CredentialRequest registers or rotates a service account. AllowedURLs is
+// only read on registration; see AllowedURLsRequest.
+type CredentialRequest struct {
+	Name           string                     `json:"name,omitempty"`
+	ServiceAccount *ServiceAccountCredentials `json:"service_account" validate:"required" binding:"required"`
+	AllowedURLs    []string                   `json:"allowed_urls,omitempty"`
+}
+
+// AllowedURLsRequest replaces the hosts and URL prefixes a registered service
+// account may submit. Entries are hosts (example.com), hosts with every
+// subdomain (*.example.com) or URL prefixes (https://example.com/blog/). An
+// empty list allows every URL.
+type AllowedURLsRequest struct {
+	AllowedURLs []string `json:"allowed_urls"`
+}
+
+// CredentialInfo describes a registered service account. It never carries the
+// private key.
+type CredentialInfo struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name,omitempty"`
+	ProjectID    string   `json:"project_id"`
+	ClientEmail  string   `json:"client_email"`
+	ClientID     string   `json:"client_id"`
+	PrivateKeyID string   `json:"private_key_id,omitempty"`
+	AllowedURLs  []string `json:"allowed_urls,omitempty"`
+	MasterKeyID  string   `json:"master_key_id"`
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+}
+
+type CredentialListResponse struct {
+	Credentials []CredentialInfo `json:"credentials"`
+	Count       int              `json:"count"`
+}
+
+// Pool strategies deciding which member of a pool gets the next URL.
+const (
+	PoolStrategyRoundRobin = "round_robin"
+	PoolStrategyLeastUsed  = "least_used"
+)
+
+// PoolRequest creates a pool of registered credentials, or replaces the
+// members of one. Name is only read when creating a pool.
+type PoolRequest struct {
+	Name          string   `json:"name,omitempty"`
+	CredentialIDs []string `json:"credential_ids" binding:"required,min=1,dive,required"`
+	Strategy      string   `json:"strategy,omitempty" binding:"omitempty,oneof=round_robin least_used"`
+}
+
+type PoolInfo struct {
+	Name          string   `json:"name"`
+	CredentialIDs []string `json:"credential_ids"`
+	Strategy      string   `json:"strategy"`
+	CreatedAt     string   `json:"created_at"`
+	UpdatedAt     string   `json:"updated_at"`
+}
+
+type PoolListResponse struct {
+	Pools []PoolInfo `json:"pools"`
+	Count int        `json:"count"`
+}
+
+type MasterKeyRotationResponse struct {
+	Success      bool   `json:"success"`
+	Message      string `json:"message"`
+	PrimaryKeyID string `json:"primary_key_id"`
+	Rewrapped    int    `json:"rewrapped"`
+}
+
+// Roles that can be granted to API keys. Admin can call every endpoint.
+const (
+	RoleSubmitter = "submitter"
+	RoleViewer    = "viewer"
+	RoleAdmin     = "admin"
+)
+
+const (
+	APIKeyStatusActive  = "active"
+	APIKeyStatusExpired = "expired"
+	APIKeyStatusRevoked = "revoked"
+)
+
+type APIKeyRequest struct {
+	Name      string     `json:"name" validate:"required" binding:"required"`
+	Roles     []string   `json:"roles" validate:"required,min=1,dive,oneof=submitter viewer admin" binding:"required,min=1,dive,oneof=submitter viewer admin"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKeyInfo describes an API key. It never carries the key itself; Hint is
+// its first characters, to tell keys apart.
+type APIKeyInfo struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Hint      string   `json:"hint,omitempty"`
+	Roles     []string `json:"roles"`
+	Status    string   `json:"status"`
+	CreatedAt string   `json:"created_at"`
+	ExpiresAt string   `json:"expires_at,omitempty"`
+	RevokedAt string   `json:"revoked_at,omitempty"`
+}
+
+// APIKeyCreatedResponse is returned once, when a key is created. Key cannot be
+// retrieved again.
+type APIKeyCreatedResponse struct {
+	APIKeyInfo
+	Key string `json:"key"`
+}
+
+type APIKeyListResponse struct {
+	Keys  []APIKeyInfo `json:"keys"`
+	Count int          `json:"count"`
+}
+
+// NotificationRecord is a notification sent to Google, as kept in the
+// submission history.
+type NotificationRecord struct {
+	ID             uint64          `json:"id"`
+	URL            string          `json:"url"`
+	Type           string          `json:"type"`
+	ServiceAccount string          `json:"service_account"`
+	ProjectID      string          `json:"project_id,omitempty"`
+	JobID          string          `json:"job_id,omitempty"`
+	Success        bool            `json:"success"`
+	GoogleResponse json.RawMessage `json:"google_response,omitempty"`
+	NotifyTime     string          `json:"notify_time"`
+	Error          string          `json:"error,omitempty"`
+	ErrorKind      string          `json:"error_kind,omitempty"`
+	Attempts       int             `json:"attempts"`
+}
+
+// HistoryResponse is a page of the submission history, newest first. Pass
+// NextCursor as the cursor parameter to get the next page.
+type HistoryResponse struct {
+	Records    []NotificationRecord `json:"records"`
+	Count      int                  `json:"count"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// QuotaResponse reports publish quota usage for the current quota day, which
+// starts at midnight Pacific time like Google's own quota.
+type QuotaResponse struct {
+	Day      string         `json:"day"`
+	TimeZone string         `json:"time_zone"`
+	ResetAt  string         `json:"reset_at"`
+	Projects []ProjectQuota `json:"projects"`
+}
+
+// ProjectQuota is the usage of one Google Cloud project. Limit is 0 and
+// Remaining is omitted when the project has no limit.
+type ProjectQuota struct {
+	ProjectID       string                `json:"project_id"`
+	Limit           int                   `json:"limit"`
+	Used            int                   `json:"used"`
+	Remaining       *int                  `json:"remaining,omitempty"`
+	ServiceAccounts []ServiceAccountQuota `json:"service_accounts"`
+}
+
+type ServiceAccountQuota struct {
+	ClientEmail string `json:"client_email"`
+	Used        int    `json:"used"`
+}
+
+// Job states. A job is canceling between a cancel request and the moment its
+// in-flight calls have returned.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCanceling = "canceling"
+	JobStatusCompleted = "completed"
+	JobStatusCanceled  = "canceled"
+	// JobStatusInterrupted marks jobs that were still running when the server
+	// stopped. They are not resumed.
+	JobStatusInterrupted = "interrupted"
+)
+
+type JobProgress struct {
+	Total      int `json:"total"`
+	Processed  int `json:"processed"`
+	Successful int `json:"successful"`
+	Failed     int `json:"failed"`
+}
+
+// JobResponse describes an asynchronous batch job. Results only lists URLs
+// that have been processed.
+type JobResponse struct {
+	ID         string          `json:"id"`
+	Status     string          `json:"status"`
+	APIKeyID   string          `json:"api_key_id,omitempty"`
+	Progress   JobProgress     `json:"progress"`
+	Results    []IndexResponse `json:"results,omitempty"`
+	CreatedAt  string          `json:"created_at"`
+	StartedAt  string          `json:"started_at,omitempty"`
+	FinishedAt string          `json:"finished_at,omitempty"`
 }
 
 type HealthResponse struct {
@@ -58,4 +404,5 @@ type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
 	Code    int    `json:"code"`
+	Reason  string `json:"reason,omitempty"`
 }
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/googleapi"
+
+	"google-indexing-api/internal/models"
+)
+
+// Accounts are the service accounts a submission may run as: a single
+// account, or the members of a pool. URLs are spread over the members by the
+// pool strategy, and move to another member when one runs out of quota.
+type Accounts struct {
+	pool     string
+	strategy string
+	members  []Credentials
+	cursor   *atomic.Uint64
+}
+
+// SingleAccount wraps credentials as Accounts with one member.
+func SingleAccount(credentials Credentials) *Accounts {
+	if credentials == nil {
+		return nil
+	}
+
+	return &Accounts{
+		strategy: models.PoolStrategyRoundRobin,
+		members:  []Credentials{credentials},
+		cursor:   new(atomic.Uint64),
+	}
+}
+
+// Pool returns the name of the pool, or an empty string for a single account.
+func (a *Accounts) Pool() string {
+	return a.pool
+}
+
+// assign picks the member to send each of items with, skipping excluded
+// members and members not eligible for the URL, and returns their indexes in
+// a.members, or -1 for items no member may send. Members whose project has
+// used up its tracked daily quota are only picked when no other member has
+// quota left. It returns nil when every member is excluded.
+func (a *Accounts) assign(items []models.BatchIndexItem, excluded []bool, quota *quotaTracker, eligible func(member int, rawURL string) bool) []int {
+	var available []int
+	for i := range a.members {
+		if !excluded[i] {
+			available = append(available, i)
+		}
+	}
+	if len(available) == 0 {
+		return nil
+	}
+
+	// Remaining quota per project, -1 meaning unlimited
+	capacity := make(map[string]int)
+	used := make([]int, len(a.members))
+	for _, i := range available {
+		projectID := a.members[i].ProjectID()
+		if _, exists := capacity[projectID]; !exists {
+			capacity[projectID] = quota.remaining(projectID)
+		}
+		used[i] = quota.accountUsed(projectID, a.members[i].ClientEmail())
+	}
+	hasCapacity := func(i int) bool {
+		return capacity[a.members[i].ProjectID()] != 0
+	}
+
+	n := len(items)
+	start := 0
+	if a.strategy == models.PoolStrategyRoundRobin {
+		start = int((a.cursor.Add(uint64(n)) - uint64(n)) % uint64(len(available)))
+	}
+
+	assignment := make([]int, n)
+	for item := range assignment {
+		pick := -1
+		fallback := -1
+
+		switch a.strategy {
+		case models.PoolStrategyLeastUsed:
+			for _, i := range available {
+				if !eligible(i, items[item].URL) {
+					continue
+				}
+				if fallback == -1 || used[i] < used[fallback] {
+					fallback = i
+				}
+				if hasCapacity(i) && (pick == -1 || used[i] < used[pick]) {
+					pick = i
+				}
+			}
+		default:
+			for k := range available {
+				i := available[(start+item+k)%len(available)]
+				if !eligible(i, items[item].URL) {
+					continue
+				}
+				if fallback == -1 {
+					fallback = i
+				}
+				if hasCapacity(i) {
+					pick = i
+					break
+				}
+			}
+		}
+
+		if pick == -1 {
+			pick = fallback
+		}
+		assignment[item] = pick
+		if pick == -1 {
+			continue
+		}
+
+		used[pick]++
+		if projectID := a.members[pick].ProjectID(); capacity[projectID] > 0 {
+			capacity[projectID]--
+		}
+	}
+
+	return assignment
+}
+
+// eligible returns whether a member of accounts may submit a URL: the URL
+// must be in the member's allowlist and, with the ownership check enabled,
+// covered by a Search Console property the member is a verified owner of.
+func (gis *GoogleIndexingService) eligible(ctx context.Context, accounts *Accounts) func(member int, rawURL string) bool {
+	return func(member int, rawURL string) bool {
+		credentials := accounts.members[member]
+		return credentials.allows(rawURL) && gis.ownsURL(ctx, credentials, rawURL)
+	}
+}
+
+// notOwnedResponse is the outcome of a URL no account of accounts may submit.
+// It explains whether the URL is outside the allowlists or outside the
+// verified Search Console properties of the accounts.
+func (a *Accounts) notOwnedResponse(item models.BatchIndexItem) (*models.IndexResponse, error) {
+	allowed := false
+	for _, member := range a.members {
+		if member.allows(item.URL) {
+			allowed = true
+			break
+		}
+	}
+
+	reason := "is not in the allowed hosts or URL prefixes of"
+	if allowed {
+		reason = "is not covered by a Search Console property owned by"
+	}
+
+	owner := "service account " + a.members[0].ClientEmail()
+	if a.pool != "" {
+		owner = "any member of pool " + a.pool
+	}
+
+	response := &models.IndexResponse{
+		Success:   false,
+		Message:   fmt.Sprintf("URL %s %s", reason, owner),
+		URL:       item.URL,
+		Type:      item.Type,
+		ErrorKind: models.ErrorKindNotOwned,
+	}
+	if a.pool == "" {
+		response.ServiceAccount = a.members[0].ClientEmail()
+	}
+	if allowed && a.pool != "" {
+		response.Message += "; add one of its service accounts as an owner of the property in Search Console"
+	} else if allowed {
+		response.Message += "; add the service account as an owner of the property in Search Console"
+	}
+
+	return response, fmt.Errorf("%w: %s", ErrNotOwned, item.URL)
+}
+
+// failoverKey marks a context whose calls can move to another member of a
+// pool, so quota errors are not worth retrying with the same account.
+type failoverKey struct{}
+
+func withFailover(ctx context.Context) context.Context {
+	return context.WithValue(ctx, failoverKey{}, true)
+}
+
+func canFailOver(ctx context.Context) bool {
+	failover, _ := ctx.Value(failoverKey{}).(bool)
+	return failover
+}
+
+// isQuotaExhausted reports whether Google rejected a call with 429
+// RESOURCE_EXHAUSTED.
+func isQuotaExhausted(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) &&
+		apiErr.Code == http.StatusTooManyRequests &&
+		strings.Contains(apiErr.Body, "RESOURCE_EXHAUSTED")
+}
+
+// shouldFailOver reports whether a result may succeed with another account.
+func shouldFailOver(result models.IndexResponse) bool {
+	return result.ErrorKind == models.ErrorKindQuotaExhausted || result.ErrorKind == models.ErrorKindCredentials
+}
+
+// submitWindow publishes up to maxNotificationsPerBatch items with accounts
+// and writes each outcome into out. Items are grouped by the member assigned
+// to them, and each group is sent as one batch call. Items a member could not
+// send, because its quota is exhausted or its credentials are unusable, are
+// moved to the remaining members until every member has been tried.
+func (gis *GoogleIndexingService) submitWindow(ctx context.Context, accounts *Accounts, items []models.BatchIndexItem, out []models.IndexResponse) {
+	excluded := make([]bool, len(accounts.members))
+	eligible := gis.eligible(ctx, accounts)
+
+	pending := make([]int, len(items))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	for len(pending) > 0 {
+		pendingItems := make([]models.BatchIndexItem, len(pending))
+		for k, index := range pending {
+			pendingItems[k] = items[index]
+		}
+
+		assignment := accounts.assign(pendingItems, excluded, gis.quota, eligible)
+		if assignment == nil {
+			return
+		}
+
+		// Items are grouped by member, and split by whether another member
+		// could take them: only then may the group skip retrying exhausted
+		// quota. Decided before any group runs, as running groups update
+		// excluded
+		groups := make(map[windowGroup][]int)
+		for k, member := range assignment {
+			if member == -1 {
+				// Only rejected on the first pass; later passes keep the
+				// failure of the member that was tried
+				if out[pending[k]].ErrorKind == "" {
+					response, _ := accounts.notOwnedResponse(items[pending[k]])
+					out[pending[k]] = *response
+				}
+				continue
+			}
+			group := windowGroup{
+				member:   member,
+				failover: accounts.hasMemberFor(member, items[pending[k]].URL, excluded, eligible),
+			}
+			groups[group] = append(groups[group], pending[k])
+		}
+
+		var next []int
+		var mutex sync.Mutex
+		var wg sync.WaitGroup
+
+		for group, indexes := range groups {
+			wg.Add(1)
+			go func(member int, indexes []int, failover bool) {
+				defer wg.Done()
+
+				chunk := make([]models.BatchIndexItem, len(indexes))
+				for k, index := range indexes {
+					chunk[k] = items[index]
+				}
+				chunkOut := make([]models.IndexResponse, len(chunk))
+
+				groupCtx := ctx
+				if failover {
+					groupCtx = withFailover(ctx)
+				}
+				gis.submitWith(groupCtx, accounts.members[member], chunk, chunkOut)
+
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				for k, index := range indexes {
+					out[index] = chunkOut[k]
+					if shouldFailOver(chunkOut[k]) {
+						excluded[member] = true
+						if failover {
+							next = append(next, index)
+						}
+					}
+				}
+			}(group.member, indexes, group.failover)
+		}
+
+		wg.Wait()
+
+		// Items no remaining member may take keep their failure
+		next = slices.DeleteFunc(next, func(index int) bool {
+			return !accounts.hasMemberFor(-1, items[index].URL, excluded, eligible)
+		})
+		if len(next) == 0 {
+			return
+		}
+
+		gis.logger.WithFields(logrus.Fields{
+			"pool":  accounts.pool,
+			"count": len(next),
+		}).Warn("Moving URLs to another pool member after quota or credential errors")
+
+		pending = next
+	}
+}
+
+// submitWith publishes items with one account, writing outcomes into out.
+func (gis *GoogleIndexingService) submitWith(ctx context.Context, credentials Credentials, items []models.BatchIndexItem, out []models.IndexResponse) {
+	client, err := gis.getIndexingService(ctx, credentials)
+	if err != nil {
+		for i, item := range items {
+			out[i] = models.IndexResponse{
+				Success:        false,
+				Message:        fmt.Sprintf("Failed to get indexing service: %v", err),
+				URL:            item.URL,
+				Type:           item.Type,
+				ServiceAccount: credentials.ClientEmail(),
+				ErrorKind:      models.ErrorKindCredentials,
+			}
+		}
+		return
+	}
+
+	gis.submitChunk(ctx, client, items, out)
+}
+
+// windowGroup is a batch call of submitWindow: the member sending it, and
+// whether its items may move to another member.
+type windowGroup struct {
+	member   int
+	failover bool
+}
+
+// hasMemberFor reports whether a member other than member that is not
+// excluded is eligible for rawURL. Pass -1 to consider every member.
+func (a *Accounts) hasMemberFor(member int, rawURL string, excluded []bool, eligible func(member int, rawURL string) bool) bool {
+	for i := range a.members {
+		if i != member && !excluded[i] && eligible(i, rawURL) {
+			return true
+		}
+	}
+	return false
+}
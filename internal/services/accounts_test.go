@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/indexing/v3"
+	"google.golang.org/api/option"
+
+	"google-indexing-api/internal/models"
+)
+
+// testCredentials are credentials whose client is added to the service cache
+// by the test. Without a client, building one fails like an undecryptable key.
+// An empty allowlist allows every URL.
+type testCredentials struct {
+	email   string
+	project string
+	allowed []string
+}
+
+func (c *testCredentials) ClientEmail() string       { return c.email }
+func (c *testCredentials) ProjectID() string         { return c.project }
+func (c *testCredentials) Fingerprint() string       { return "test:" + c.email }
+func (c *testCredentials) allows(rawURL string) bool { return urlAllowed(c.allowed, rawURL) }
+
+func (c *testCredentials) serviceAccount() (*models.ServiceAccountCredentials, error) {
+	return nil, errors.New("failed to decrypt private key")
+}
+
+// whoHeader tells the fake Google server which account sent a request.
+const whoHeader = "X-Test-Account"
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func newTestService(t *testing.T, server *httptest.Server) *GoogleIndexingService {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	quota, err := newQuotaTracker(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &GoogleIndexingService{
+		logger:         logger,
+		serviceCache:   newServiceCache(time.Hour, 100),
+		batchEndpoint:  server.URL + "/batch",
+		workers:        newWorkerPool(10),
+		retry:          newRetryPolicy(2, time.Millisecond),
+		requestTimeout: 5 * time.Second,
+		quota:          quota,
+		outbound:       newOutboundLimiter(0, 0),
+	}
+}
+
+// addTestClient caches a client for credentials that talks to server and
+// names its account in whoHeader.
+func addTestClient(t *testing.T, gis *GoogleIndexingService, server *httptest.Server, credentials *testCredentials) *indexingClient {
+	t.Helper()
+
+	httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req.Header.Set(whoHeader, credentials.email)
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+
+	service, err := indexing.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithHTTPClient(httpClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &indexingClient{
+		service:     service,
+		httpClient:  httpClient,
+		clientEmail: credentials.email,
+		projectID:   credentials.project,
+	}
+	gis.serviceCache.add(credentials.Fingerprint(), credentials.email, client)
+
+	return client
+}
+
+func testItems(n int) []models.BatchIndexItem {
+	items := make([]models.BatchIndexItem, n)
+	for i := range items {
+		items[i] = models.BatchIndexItem{URL: fmt.Sprintf("https://example.com/%d", i), Type: models.NotificationTypeUpdated}
+	}
+	return items
+}
+
+func TestSubmitURLsBatchPoolFailover(t *testing.T) {
+	var exhaustedCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/batch" {
+			// Force individual publishes
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get(whoHeader) != "good@p2" {
+			exhaustedCalls.Add(1)
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"urlNotificationMetadata":{"url":"x"}}`)
+	}))
+	defer server.Close()
+
+	gis := newTestService(t, server)
+
+	// Members without a client fail like credentials that cannot be decrypted
+	var members []Credentials
+	for i := 0; i < 8; i++ {
+		members = append(members, &testCredentials{email: fmt.Sprintf("broken%d@p1", i), project: "p1"})
+	}
+	exhausted := &testCredentials{email: "exhausted@p1", project: "p1"}
+	good := &testCredentials{email: "good@p2", project: "p2"}
+	addTestClient(t, gis, server, exhausted)
+	addTestClient(t, gis, server, good)
+	members = append(members, exhausted, good)
+
+	accounts := &Accounts{
+		pool:     "test",
+		strategy: models.PoolStrategyRoundRobin,
+		members:  members,
+		cursor:   new(atomic.Uint64),
+	}
+
+	response, err := gis.SubmitURLsBatch(context.Background(), testItems(50), accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Statistics.Successful != 50 {
+		t.Fatalf("got %d successful URLs, want 50", response.Statistics.Successful)
+	}
+	for _, result := range response.Results {
+		if result.ServiceAccount != good.email {
+			t.Errorf("%s was sent with %s, want %s", result.URL, result.ServiceAccount, good.email)
+		}
+	}
+
+	// Exhausted quota moves on to another member instead of being retried
+	if calls := int(exhaustedCalls.Load()); calls > 50 {
+		t.Errorf("exhausted account got %d calls, want at most one per URL", calls)
+	}
+}
+
+func TestSubmitURLsBatchSingleAccountRetriesExhaustedQuota(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/batch" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`)
+	}))
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	credentials := &testCredentials{email: "only@p1", project: "p1"}
+	addTestClient(t, gis, server, credentials)
+
+	response, err := gis.SubmitURLsBatch(context.Background(), testItems(1), SingleAccount(credentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := response.Results[0]
+	if result.ErrorKind != models.ErrorKindQuotaExhausted || result.Attempts != 3 {
+		t.Errorf("got error kind %q after %d attempts, want %q after 3", result.ErrorKind, result.Attempts, models.ErrorKindQuotaExhausted)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("got %d calls, want 3", calls.Load())
+	}
+}
+
+func TestSubmitURLsBatchFailsOverOnlyToEligibleMembers(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/batch" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`)
+	}))
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	exhausted := &testCredentials{email: "exhausted@p1", project: "p1"}
+	other := &testCredentials{email: "other@p2", project: "p2", allowed: []string{"other.test"}}
+	addTestClient(t, gis, server, exhausted)
+	addTestClient(t, gis, server, other)
+
+	accounts := &Accounts{
+		pool:     "test",
+		strategy: models.PoolStrategyRoundRobin,
+		members:  []Credentials{exhausted, other},
+		cursor:   new(atomic.Uint64),
+	}
+
+	// Only exhausted may send the URL, so it retries instead of failing over
+	response, err := gis.SubmitURLsBatch(context.Background(), testItems(1), accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := response.Results[0]
+	if result.ErrorKind != models.ErrorKindQuotaExhausted || result.ServiceAccount != exhausted.email || result.Attempts != 3 {
+		t.Errorf("got error kind %q from %s after %d attempts, want %q from %s after 3", result.ErrorKind, result.ServiceAccount, result.Attempts, models.ErrorKindQuotaExhausted, exhausted.email)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("got %d calls, want 3", calls.Load())
+	}
+}
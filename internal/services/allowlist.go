@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidAllowedURLs is returned for allowlist entries that are neither a
+// host nor an http(s) URL prefix.
+var ErrInvalidAllowedURLs = errors.New("invalid allowed_urls")
+
+// normalizeAllowedURLs validates the allowlist of a credential. An entry is
+// either a host, optionally with a leading "*." to allow every subdomain, or
+// an http(s) URL prefix such as https://example.com/blog/. Hosts are
+// lowercased and duplicates dropped.
+func normalizeAllowedURLs(entries []string) ([]string, error) {
+	normalized := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			return nil, fmt.Errorf("%w: entries cannot be empty", ErrInvalidAllowedURLs)
+		}
+
+		if strings.Contains(entry, "://") {
+			u, err := url.Parse(entry)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("%w: %q is not an http(s) URL prefix", ErrInvalidAllowedURLs, entry)
+			}
+			normalized = append(normalized, urlPrefix(u))
+			continue
+		}
+
+		host := strings.ToLower(entry)
+		if strings.ContainsAny(strings.TrimPrefix(host, "*."), "/*:?# ") {
+			return nil, fmt.Errorf("%w: %q is not a host", ErrInvalidAllowedURLs, entry)
+		}
+		normalized = append(normalized, host)
+	}
+
+	return uniqueStrings(normalized), nil
+}
+
+// urlAllowed reports whether rawURL matches an entry of allowed. An empty
+// allowlist allows every URL.
+func urlAllowed(allowed []string, rawURL string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	prefix := urlPrefix(u)
+
+	for _, entry := range allowed {
+		switch {
+		case strings.Contains(entry, "://"):
+			if strings.HasPrefix(prefix, entry) {
+				return true
+			}
+		case strings.HasPrefix(entry, "*."):
+			if strings.HasSuffix(host, entry[1:]) {
+				return true
+			}
+		case host == entry:
+			return true
+		}
+	}
+
+	return false
+}
+
+// urlPrefix lowercases the scheme and host of u, which are case-insensitive,
+// and keeps its path. An empty path becomes "/", so a prefix naming only a
+// host cannot match a longer host.
+func urlPrefix(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	return strings.ToLower(u.Scheme+"://"+u.Host) + path
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/pkg/utils"
+)
+
+var (
+	// ErrAPIKeyNotFound is returned for unknown API key IDs.
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAPIKeyInvalid is returned when a presented key matches no stored key.
+	ErrAPIKeyInvalid = errors.New("invalid API key")
+	// ErrAPIKeyExpired is returned when a presented key is past its expiry.
+	ErrAPIKeyExpired = errors.New("API key has expired")
+	// ErrAPIKeyRevoked is returned when a presented key has been revoked.
+	ErrAPIKeyRevoked = errors.New("API key has been revoked")
+)
+
+const (
+	apiKeyStoreVersion = 1
+
+	// apiKeyPrefix marks keys issued by this server, which makes leaked keys
+	// easy to find with secret scanners.
+	apiKeyPrefix = "gia_"
+
+	// bootstrapKeyName names the key configured with API_KEY.
+	bootstrapKeyName = "bootstrap"
+
+	// minBootstrapKeyLength is the shortest API_KEY accepted. The bootstrap
+	// key is an admin key, so it must not be guessable.
+	minBootstrapKeyLength = 24
+)
+
+// placeholderAPIKeys are example values from the docs and scripts, which
+// anyone can read and must never be accepted as the bootstrap key.
+var placeholderAPIKeys = map[string]bool{
+	"your-secure-api-key": true,
+	"your-api-key-here":   true,
+	"your-api-key":        true,
+	"changeme":            true,
+}
+
+// APIKeyStore keeps the API keys allowed to call the API. Only the SHA-256 hash
+// of each key is stored; the key itself is shown once, when it is created.
+type APIKeyStore struct {
+	logger *logrus.Logger
+	path   string
+	keys   map[string]*storedAPIKey
+	hashes map[string]*storedAPIKey
+	mutex  sync.RWMutex
+}
+
+type storedAPIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash"`
+	Hint      string     `json:"hint"`
+	Roles     []string   `json:"roles"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	// bootstrap marks the key configured with API_KEY, which is never saved.
+	bootstrap bool
+}
+
+type apiKeyStoreFile struct {
+	Version int             `json:"version"`
+	Keys    []*storedAPIKey `json:"keys"`
+}
+
+// NewAPIKeyStore loads the keys persisted at path. An empty path keeps keys in
+// memory only. A non-empty bootstrapKey is always accepted, so a fresh server
+// can be used to create the first real keys. A placeholder or short
+// bootstrapKey is refused, since it would give anyone admin access.
+func NewAPIKeyStore(path string, bootstrapKey string, logger *logrus.Logger) (*APIKeyStore, error) {
+	if err := validateBootstrapKey(bootstrapKey); err != nil {
+		logger.WithError(err).Error("Refusing to start with an unsafe API_KEY")
+		return nil, err
+	}
+
+	ks := &APIKeyStore{
+		logger: logger,
+		path:   path,
+		keys:   make(map[string]*storedAPIKey),
+		hashes: make(map[string]*storedAPIKey),
+	}
+
+	if path != "" {
+		var file apiKeyStoreFile
+		if _, err := utils.ReadJSONFile(path, &file); err != nil {
+			return nil, fmt.Errorf("failed to load API key store: %v", err)
+		}
+
+		for _, key := range file.Keys {
+			// Keys created before roles existed could only submit URLs
+			if len(key.Roles) == 0 {
+				key.Roles = []string{models.RoleSubmitter}
+			}
+			ks.keys[key.ID] = key
+			ks.hashes[key.Hash] = key
+		}
+	}
+
+	if bootstrapKey != "" {
+		ks.addBootstrapKey(bootstrapKey)
+	}
+
+	if len(ks.keys) == 0 {
+		logger.Warn("No API keys configured; set API_KEY to bootstrap access to /api/v1")
+	}
+
+	return ks, nil
+}
+
+// Create issues a new API key. The returned response is the only place the
+// plain key ever appears.
+func (ks *APIKeyStore) Create(name string, roles []string, expiresAt *time.Time) (*models.APIKeyCreatedResponse, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API key ID: %v", err)
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %v", err)
+	}
+
+	plain := apiKeyPrefix + secret
+	key := &storedAPIKey{
+		ID:        "key_" + id,
+		Name:      name,
+		Hash:      HashAPIKey(plain),
+		Hint:      plain[:len(apiKeyPrefix)+4],
+		Roles:     normalizeRoles(roles),
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	ks.keys[key.ID] = key
+	ks.hashes[key.Hash] = key
+	if err := ks.save(); err != nil {
+		delete(ks.keys, key.ID)
+		delete(ks.hashes, key.Hash)
+		return nil, err
+	}
+
+	ks.logger.WithFields(logrus.Fields{
+		"api_key_id": key.ID,
+		"name":       name,
+		"roles":      key.Roles,
+	}).Info("Created API key")
+
+	return &models.APIKeyCreatedResponse{
+		APIKeyInfo: key.info(time.Now()),
+		Key:        plain,
+	}, nil
+}
+
+// List returns every key, including expired and revoked ones, oldest first.
+func (ks *APIKeyStore) List() []models.APIKeyInfo {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	keys := make([]*storedAPIKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	now := time.Now()
+	list := make([]models.APIKeyInfo, len(keys))
+	for i, key := range keys {
+		list[i] = key.info(now)
+	}
+
+	return list
+}
+
+// Revoke disables a key. Revoked keys stay listed so their use can still be
+// traced in the logs.
+func (ks *APIKeyStore) Revoke(id string) (*models.APIKeyInfo, error) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	key, exists := ks.keys[id]
+	if !exists {
+		return nil, ErrAPIKeyNotFound
+	}
+	if key.bootstrap {
+		return nil, errors.New("the bootstrap key is configured with API_KEY; unset it to revoke the key")
+	}
+
+	now := time.Now().UTC()
+	if key.RevokedAt == nil {
+		revoked := *key
+		revoked.RevokedAt = &now
+
+		ks.keys[id] = &revoked
+		ks.hashes[key.Hash] = &revoked
+		if err := ks.save(); err != nil {
+			ks.keys[id] = key
+			ks.hashes[key.Hash] = key
+			return nil, err
+		}
+
+		key = &revoked
+		ks.logger.WithField("api_key_id", id).Info("Revoked API key")
+	}
+
+	info := key.info(now)
+	return &info, nil
+}
+
+// Authenticate returns the key matching plain, or an error saying why it
+// cannot be used.
+func (ks *APIKeyStore) Authenticate(plain string) (*models.APIKeyInfo, error) {
+	ks.mutex.RLock()
+	key, exists := ks.hashes[HashAPIKey(plain)]
+	ks.mutex.RUnlock()
+
+	if !exists {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	info := key.info(now)
+	return &info, nil
+}
+
+// validateBootstrapKey rejects an API_KEY copied from the docs or short
+// enough to guess. An empty key is valid and disables the bootstrap key.
+func validateBootstrapKey(plain string) error {
+	switch {
+	case plain == "":
+		return nil
+	case placeholderAPIKeys[strings.ToLower(plain)]:
+		return fmt.Errorf("API_KEY is the documented placeholder %q, generate one with: openssl rand -hex 24", plain)
+	case len(plain) < minBootstrapKeyLength:
+		return fmt.Errorf("API_KEY must be at least %d characters, generate one with: openssl rand -hex 24", minBootstrapKeyLength)
+	}
+
+	return nil
+}
+
+// addBootstrapKey makes sure the key configured with API_KEY is accepted as an
+// admin key. It is only kept in memory, so removing API_KEY removes the key.
+func (ks *APIKeyStore) addBootstrapKey(plain string) {
+	hash := HashAPIKey(plain)
+	if _, exists := ks.hashes[hash]; exists {
+		return
+	}
+
+	key := &storedAPIKey{
+		ID:        "key_" + bootstrapKeyName,
+		Name:      bootstrapKeyName,
+		Hash:      hash,
+		Roles:     []string{models.RoleAdmin},
+		CreatedAt: time.Now().UTC(),
+		bootstrap: true,
+	}
+	ks.keys[key.ID] = key
+	ks.hashes[hash] = key
+}
+
+// save persists the store. Callers must hold ks.mutex.
+func (ks *APIKeyStore) save() error {
+	if ks.path == "" {
+		return nil
+	}
+
+	file := apiKeyStoreFile{
+		Version: apiKeyStoreVersion,
+		Keys:    make([]*storedAPIKey, 0, len(ks.keys)),
+	}
+	for _, key := range ks.keys {
+		if key.bootstrap {
+			continue
+		}
+		file.Keys = append(file.Keys, key)
+	}
+
+	if err := utils.WriteJSONFile(ks.path, &file, 0o600); err != nil {
+		return fmt.Errorf("failed to save API key store: %v", err)
+	}
+
+	return nil
+}
+
+func (k *storedAPIKey) info(now time.Time) models.APIKeyInfo {
+	info := models.APIKeyInfo{
+		ID:        k.ID,
+		Name:      k.Name,
+		Hint:      k.Hint,
+		Roles:     k.Roles,
+		Status:    models.APIKeyStatusActive,
+		CreatedAt: k.CreatedAt.Format(time.RFC3339),
+	}
+
+	if k.ExpiresAt != nil {
+		info.ExpiresAt = k.ExpiresAt.UTC().Format(time.RFC3339)
+		if !now.Before(*k.ExpiresAt) {
+			info.Status = models.APIKeyStatusExpired
+		}
+	}
+	if k.RevokedAt != nil {
+		info.RevokedAt = k.RevokedAt.UTC().Format(time.RFC3339)
+		info.Status = models.APIKeyStatusRevoked
+	}
+
+	return info
+}
+
+// normalizeRoles sorts roles and drops duplicates.
+func normalizeRoles(roles []string) []string {
+	seen := make(map[string]bool, len(roles))
+	normalized := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if !seen[role] {
+			seen[role] = true
+			normalized = append(normalized, role)
+		}
+	}
+
+	sort.Strings(normalized)
+	return normalized
+}
+
+// HashAPIKey returns the hex encoded SHA-256 hash a key is stored under. API
+// keys are long random strings, so a fast hash is enough.
+func HashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewAPIKeyStoreRefusesUnsafeBootstrapKey(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	for _, key := range []string{"your-secure-api-key", "YOUR-API-KEY-HERE", "short-key"} {
+		if _, err := NewAPIKeyStore("", key, logger); err == nil {
+			t.Errorf("API_KEY %q was accepted", key)
+		}
+	}
+
+	ks, err := NewAPIKeyStore("", "", logger)
+	if err != nil || len(ks.List()) != 0 {
+		t.Errorf("empty API_KEY: got %v, want a store without keys", err)
+	}
+
+	const strong = "0123456789abcdef0123456789abcdef"
+	ks, err = NewAPIKeyStore("", strong, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Authenticate(strong); err != nil {
+		t.Errorf("bootstrap key was not accepted: %v", err)
+	}
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/pkg/utils"
+)
+
+// ErrCredentialNotFound is returned for unknown credential IDs.
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// ErrClientEmailMismatch is returned when a rotated key belongs to another
+// service account.
+var ErrClientEmailMismatch = errors.New("client_email mismatch")
+
+// credentialStoreVersion is the on-disk format version of the store file.
+const credentialStoreVersion = 1
+
+// CredentialStore keeps service accounts registered on the server, so clients
+// can refer to them by an opaque ID instead of sending the private key with
+// every request. Private keys never leave the store through its public API.
+//
+// Private keys are kept encrypted with the keyring, in memory and on disk.
+// They are only decrypted when the indexing service builds a client.
+type CredentialStore struct {
+	logger      *logrus.Logger
+	keyring     *Keyring
+	path        string
+	credentials map[string]*storedCredential
+	mutex       sync.RWMutex
+}
+
+// storedCredential is a registered service account. The PrivateKey field of
+// Account is always empty; the key itself lives in Secret. AllowedURLs limits
+// the URLs the account may submit, see urlAllowed.
+type storedCredential struct {
+	ID          string                           `json:"id"`
+	Name        string                           `json:"name,omitempty"`
+	Account     models.ServiceAccountCredentials `json:"account"`
+	Secret      *encryptedSecret                 `json:"secret"`
+	KeyHash     string                           `json:"fingerprint"`
+	AllowedURLs []string                         `json:"allowed_urls,omitempty"`
+	CreatedAt   time.Time                        `json:"created_at"`
+	UpdatedAt   time.Time                        `json:"updated_at"`
+	keyring     *Keyring
+}
+
+type credentialStoreFile struct {
+	Version     int                 `json:"version"`
+	Credentials []*storedCredential `json:"credentials"`
+}
+
+// NewCredentialStore loads the store persisted at path. An empty path keeps
+// credentials in memory only. Secrets still wrapped with an older master key
+// are re-wrapped with the primary key on load.
+func NewCredentialStore(path string, keyring *Keyring, logger *logrus.Logger) (*CredentialStore, error) {
+	cs := &CredentialStore{
+		logger:      logger,
+		keyring:     keyring,
+		path:        path,
+		credentials: make(map[string]*storedCredential),
+	}
+
+	if path == "" {
+		return cs, nil
+	}
+
+	var file credentialStoreFile
+	if _, err := utils.ReadJSONFile(path, &file); err != nil {
+		return nil, fmt.Errorf("failed to load credential store: %v", err)
+	}
+
+	for _, credential := range file.Credentials {
+		credential.keyring = keyring
+		cs.credentials[credential.ID] = credential
+	}
+
+	if _, err := cs.RewrapSecrets(); err != nil {
+		return nil, err
+	}
+
+	logger.WithField("count", len(cs.credentials)).Info("Loaded credential store")
+
+	return cs, nil
+}
+
+// Register stores a service account and returns its public description,
+// including the generated credential ID. An empty allowedURLs lets the account
+// submit any URL.
+func (cs *CredentialStore) Register(name string, serviceAccount *models.ServiceAccountCredentials, allowedURLs []string) (*models.CredentialInfo, error) {
+	allowedURLs, err := normalizeAllowedURLs(allowedURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newCredentialID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate credential ID: %v", err)
+	}
+
+	now := time.Now().UTC()
+	credential := &storedCredential{
+		ID:          id,
+		Name:        name,
+		AllowedURLs: allowedURLs,
+		CreatedAt:   now,
+		keyring:     cs.keyring,
+	}
+
+	// Sealed under the lock, so a master key rotation cannot drop the key
+	// in between
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if err := credential.setAccount(serviceAccount, now); err != nil {
+		return nil, err
+	}
+
+	cs.credentials[id] = credential
+	if err := cs.save(); err != nil {
+		delete(cs.credentials, id)
+		return nil, err
+	}
+
+	cs.logger.WithFields(logrus.Fields{
+		"credential_id":   id,
+		"service_account": serviceAccount.ClientEmail,
+	}).Info("Registered service account credential")
+
+	info := credential.info()
+	return &info, nil
+}
+
+// Get returns the public description of a credential.
+func (cs *CredentialStore) Get(id string) (*models.CredentialInfo, error) {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	credential, exists := cs.credentials[id]
+	if !exists {
+		return nil, ErrCredentialNotFound
+	}
+
+	info := credential.info()
+	return &info, nil
+}
+
+// List returns every registered credential, oldest first.
+func (cs *CredentialStore) List() []models.CredentialInfo {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	credentials := make([]*storedCredential, 0, len(cs.credentials))
+	for _, credential := range cs.credentials {
+		credentials = append(credentials, credential)
+	}
+
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.Before(credentials[j].CreatedAt)
+	})
+
+	list := make([]models.CredentialInfo, len(credentials))
+	for i, credential := range credentials {
+		list[i] = credential.info()
+	}
+
+	return list
+}
+
+// Rotate replaces the key of a registered credential. The new key must belong
+// to the same service account. A non-empty name also renames the credential.
+func (cs *CredentialStore) Rotate(id string, name string, serviceAccount *models.ServiceAccountCredentials) (*models.CredentialInfo, error) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	credential, exists := cs.credentials[id]
+	if !exists {
+		return nil, ErrCredentialNotFound
+	}
+
+	if serviceAccount.ClientEmail != credential.Account.ClientEmail {
+		return nil, fmt.Errorf("%w: client_email must stay %s when rotating a key", ErrClientEmailMismatch, credential.Account.ClientEmail)
+	}
+
+	rotated := *credential
+	if err := rotated.setAccount(serviceAccount, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if name != "" {
+		rotated.Name = name
+	}
+
+	cs.credentials[id] = &rotated
+	if err := cs.save(); err != nil {
+		cs.credentials[id] = credential
+		return nil, err
+	}
+
+	cs.logger.WithFields(logrus.Fields{
+		"credential_id":   id,
+		"service_account": serviceAccount.ClientEmail,
+		"private_key_id":  serviceAccount.PrivateKeyID,
+	}).Info("Rotated service account credential")
+
+	info := rotated.info()
+	return &info, nil
+}
+
+// SetAllowedURLs replaces the hosts and URL prefixes a credential may submit.
+// An empty list lets it submit any URL.
+func (cs *CredentialStore) SetAllowedURLs(id string, allowedURLs []string) (*models.CredentialInfo, error) {
+	allowedURLs, err := normalizeAllowedURLs(allowedURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	credential, exists := cs.credentials[id]
+	if !exists {
+		return nil, ErrCredentialNotFound
+	}
+
+	updated := *credential
+	updated.AllowedURLs = allowedURLs
+	updated.UpdatedAt = time.Now().UTC()
+
+	cs.credentials[id] = &updated
+	if err := cs.save(); err != nil {
+		cs.credentials[id] = credential
+		return nil, err
+	}
+
+	cs.logger.WithFields(logrus.Fields{
+		"credential_id": id,
+		"allowed_urls":  len(allowedURLs),
+	}).Info("Updated allowed URLs of service account credential")
+
+	info := updated.info()
+	return &info, nil
+}
+
+// Delete removes a credential.
+func (cs *CredentialStore) Delete(id string) error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	credential, exists := cs.credentials[id]
+	if !exists {
+		return ErrCredentialNotFound
+	}
+
+	delete(cs.credentials, id)
+	if err := cs.save(); err != nil {
+		cs.credentials[id] = credential
+		return err
+	}
+
+	cs.logger.WithField("credential_id", id).Info("Deleted service account credential")
+
+	return nil
+}
+
+// Credentials returns the credential registered under id for use with the
+// indexing service. It is a snapshot: rotating the key later does not affect
+// calls already holding it.
+func (cs *CredentialStore) Credentials(id string) (Credentials, error) {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	credential, exists := cs.credentials[id]
+	if !exists {
+		return nil, ErrCredentialNotFound
+	}
+
+	snapshot := *credential
+	return &snapshot, nil
+}
+
+// RotateMasterKey reloads the master keys and re-wraps every secret with the
+// new primary key. Older keys must stay loaded until this has run, so
+// credentials stay usable throughout; keys that cannot decrypt every stored
+// secret are refused and the loaded keys kept.
+func (cs *CredentialStore) RotateMasterKey() (*models.MasterKeyRotationResponse, error) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	secrets := make([]*encryptedSecret, 0, len(cs.credentials))
+	for _, credential := range cs.credentials {
+		secrets = append(secrets, credential.Secret)
+	}
+
+	if err := cs.keyring.Reload(secrets...); err != nil {
+		return nil, fmt.Errorf("failed to reload master keys: %v", err)
+	}
+
+	rewrapped, err := cs.rewrapSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MasterKeyRotationResponse{
+		Success:      true,
+		Message:      fmt.Sprintf("Re-wrapped %d credentials with the primary master key", rewrapped),
+		PrimaryKeyID: cs.keyring.PrimaryKeyID(),
+		Rewrapped:    rewrapped,
+	}, nil
+}
+
+// RewrapSecrets re-wraps the data key of every secret that is not yet wrapped
+// with the primary master key, and returns how many were changed.
+func (cs *CredentialStore) RewrapSecrets() (int, error) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	return cs.rewrapSecrets()
+}
+
+// rewrapSecrets is RewrapSecrets for callers holding cs.mutex.
+func (cs *CredentialStore) rewrapSecrets() (int, error) {
+	previous := make(map[string]*storedCredential)
+	for id, credential := range cs.credentials {
+		secret, changed, err := cs.keyring.rewrap(credential.Secret)
+		if err != nil {
+			return 0, fmt.Errorf("failed to re-wrap credential %s: %v", id, err)
+		}
+		if !changed {
+			continue
+		}
+
+		rewrapped := *credential
+		rewrapped.Secret = secret
+		previous[id] = credential
+		cs.credentials[id] = &rewrapped
+	}
+
+	if len(previous) == 0 {
+		return 0, nil
+	}
+
+	if err := cs.save(); err != nil {
+		for id, credential := range previous {
+			cs.credentials[id] = credential
+		}
+		return 0, err
+	}
+
+	cs.logger.WithFields(logrus.Fields{
+		"count":          len(previous),
+		"primary_key_id": cs.keyring.PrimaryKeyID(),
+	}).Info("Re-wrapped credential secrets with primary master key")
+
+	return len(previous), nil
+}
+
+// save persists the store. Callers must hold cs.mutex.
+func (cs *CredentialStore) save() error {
+	if cs.path == "" {
+		return nil
+	}
+
+	file := credentialStoreFile{
+		Version:     credentialStoreVersion,
+		Credentials: make([]*storedCredential, 0, len(cs.credentials)),
+	}
+	for _, credential := range cs.credentials {
+		file.Credentials = append(file.Credentials, credential)
+	}
+
+	if err := utils.WriteJSONFile(cs.path, &file, 0o600); err != nil {
+		return fmt.Errorf("failed to save credential store: %v", err)
+	}
+
+	return nil
+}
+
+// setAccount encrypts the private key of serviceAccount and stores the rest of
+// the account in the clear.
+func (c *storedCredential) setAccount(serviceAccount *models.ServiceAccountCredentials, now time.Time) error {
+	secret, err := c.keyring.seal([]byte(serviceAccount.PrivateKey), []byte(c.ID))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt private key: %v", err)
+	}
+
+	c.Account = *serviceAccount
+	c.Account.PrivateKey = ""
+	c.Secret = secret
+	c.KeyHash = credentialFingerprint(serviceAccount)
+	c.UpdatedAt = now
+
+	return nil
+}
+
+func (c *storedCredential) ClientEmail() string {
+	return c.Account.ClientEmail
+}
+
+func (c *storedCredential) ProjectID() string {
+	return c.Account.ProjectID
+}
+
+func (c *storedCredential) Fingerprint() string {
+	return c.KeyHash
+}
+
+func (c *storedCredential) allows(rawURL string) bool {
+	return urlAllowed(c.AllowedURLs, rawURL)
+}
+
+// serviceAccount decrypts the private key. It is only called by
+// getIndexingService when a client has to be built.
+func (c *storedCredential) serviceAccount() (*models.ServiceAccountCredentials, error) {
+	privateKey, err := c.keyring.open(c.Secret, []byte(c.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key: %v", err)
+	}
+
+	account := c.Account
+	account.PrivateKey = string(privateKey)
+	return &account, nil
+}
+
+// info describes the credential without its private key.
+func (c *storedCredential) info() models.CredentialInfo {
+	return models.CredentialInfo{
+		ID:           c.ID,
+		Name:         c.Name,
+		ProjectID:    c.Account.ProjectID,
+		ClientEmail:  c.Account.ClientEmail,
+		ClientID:     c.Account.ClientID,
+		PrivateKeyID: c.Account.PrivateKeyID,
+		AllowedURLs:  c.AllowedURLs,
+		MasterKeyID:  c.Secret.KeyID,
+		CreatedAt:    c.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:    c.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func newCredentialID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return "cred_" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"google-indexing-api/internal/models"
+)
+
+func writeMasterKeys(t *testing.T, path string, keys ...string) {
+	t.Helper()
+
+	content := ""
+	for _, key := range keys {
+		content += key + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newMasterKey(t *testing.T) string {
+	t.Helper()
+
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestRotateMasterKey(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "master.key")
+	oldKey, newKey := newMasterKey(t), newMasterKey(t)
+	writeMasterKeys(t, keyFile, oldKey)
+
+	keyring, err := LoadKeyring(KeyringConfig{MasterKeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	store, err := NewCredentialStore(filepath.Join(dir, "credentials.json"), keyring, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := store.Register("test", &models.ServiceAccountCredentials{
+		ProjectID:   "p1",
+		ClientEmail: "a@p1.iam.gserviceaccount.com",
+		PrivateKey:  "private key",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKeyID := keyring.PrimaryKeyID()
+
+	privateKey := func() string {
+		t.Helper()
+
+		credentials, err := store.Credentials(info.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		account, err := credentials.serviceAccount()
+		if err != nil {
+			t.Fatalf("credential cannot be decrypted: %v", err)
+		}
+		return account.PrivateKey
+	}
+
+	// Replacing the old key instead of adding the new one is refused
+	writeMasterKeys(t, keyFile, newKey)
+	if _, err := store.RotateMasterKey(); err == nil {
+		t.Fatal("rotation without the previous key succeeded")
+	}
+	if keyring.PrimaryKeyID() != oldKeyID {
+		t.Error("refused rotation replaced the loaded keys")
+	}
+	if privateKey() != "private key" {
+		t.Error("private key changed after a refused rotation")
+	}
+
+	writeMasterKeys(t, keyFile, newKey, oldKey)
+	response, err := store.RotateMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Rewrapped != 1 || response.PrimaryKeyID == oldKeyID {
+		t.Errorf("got %d re-wrapped with %s, want 1 with a new key", response.Rewrapped, response.PrimaryKeyID)
+	}
+
+	// The old key can go once everything is re-wrapped
+	writeMasterKeys(t, keyFile, newKey)
+	if _, err := store.RotateMasterKey(); err != nil {
+		t.Fatal(err)
+	}
+	if privateKey() != "private key" {
+		t.Error("private key changed after rotation")
+	}
+}
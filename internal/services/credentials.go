@@ -0,0 +1,61 @@
+package services
+
+import (
+	"google-indexing-api/internal/models"
+)
+
+// Credentials identifies the service account a Google API call runs as. It is
+// either sent inline with a request or registered in the CredentialStore.
+type Credentials interface {
+	ClientEmail() string
+	ProjectID() string
+
+	// Fingerprint identifies the exact key, see credentialFingerprint.
+	Fingerprint() string
+
+	// allows reports whether the account may submit rawURL, see urlAllowed.
+	allows(rawURL string) bool
+
+	// serviceAccount returns the full credentials, private key included. It is
+	// only called when a client has to be built.
+	serviceAccount() (*models.ServiceAccountCredentials, error)
+}
+
+// inlineCredentials are credentials sent in the request body.
+type inlineCredentials struct {
+	account     *models.ServiceAccountCredentials
+	fingerprint string
+}
+
+// InlineCredentials wraps a service account sent with a request.
+func InlineCredentials(serviceAccount *models.ServiceAccountCredentials) Credentials {
+	if serviceAccount == nil {
+		return nil
+	}
+
+	return &inlineCredentials{
+		account:     serviceAccount,
+		fingerprint: credentialFingerprint(serviceAccount),
+	}
+}
+
+func (c *inlineCredentials) ClientEmail() string {
+	return c.account.ClientEmail
+}
+
+func (c *inlineCredentials) ProjectID() string {
+	return c.account.ProjectID
+}
+
+func (c *inlineCredentials) Fingerprint() string {
+	return c.fingerprint
+}
+
+// allows accepts every URL, as inline credentials carry no allowlist.
+func (c *inlineCredentials) allows(rawURL string) bool {
+	return true
+}
+
+func (c *inlineCredentials) serviceAccount() (*models.ServiceAccountCredentials, error) {
+	return c.account, nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"google.golang.org/api/googleapi"
+
+	"google-indexing-api/internal/models"
+)
+
+// ErrCallTimeout is returned when a single call to Google runs past its
+// per-call deadline.
+var ErrCallTimeout = errors.New("google api call timed out")
+
+// ErrNotOwned is returned for URLs outside the allowed hosts and URL prefixes
+// of the service account. Such URLs are never sent to Google.
+var ErrNotOwned = errors.New("URL is not allowed for the service account")
+
+// resultCode labels the outcome of a Google API call in metrics: the HTTP
+// status code, or the error kind when Google sent no response.
+func resultCode(err error) string {
+	var apiErr *googleapi.Error
+
+	switch {
+	case err == nil:
+		return "200"
+	case errors.As(err, &apiErr):
+		return strconv.Itoa(apiErr.Code)
+	default:
+		return errorKind(err)
+	}
+}
+
+// errorKind classifies an error from a Google API call for API responses.
+func errorKind(err error) string {
+	var apiErr *googleapi.Error
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrNotOwned):
+		return models.ErrorKindNotOwned
+	case errors.Is(err, ErrCallTimeout):
+		return models.ErrorKindTimeout
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return models.ErrorKindCanceled
+	case isQuotaExhausted(err):
+		return models.ErrorKindQuotaExhausted
+	case errors.As(err, &apiErr):
+		return models.ErrorKindGoogleAPI
+	case isNetworkError(err):
+		return models.ErrorKindNetwork
+	default:
+		return models.ErrorKindInternal
+	}
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/indexing/v3"
+
+	"google-indexing-api/internal/metrics"
+	"google-indexing-api/internal/models"
+)
+
+const (
+	// indexingBatchEndpoint is the Google Indexing API batch endpoint that
+	// accepts multipart/mixed requests.
+	indexingBatchEndpoint = "https://indexing.googleapis.com/batch"
+
+	// indexingPublishPath is the request path of a single publish call inside a batch.
+	indexingPublishPath = "/v3/urlNotifications:publish"
+
+	// maxNotificationsPerBatch is the maximum number of calls Google accepts
+	// in a single batch request.
+	maxNotificationsPerBatch = 100
+)
+
+// batchItemResult is the outcome of a single notification inside a batch call.
+// A nil err with a nil metadata means Google did not return a part for the item.
+type batchItemResult struct {
+	metadata *indexing.PublishUrlNotificationResponse
+	err      error
+}
+
+// publishBatch sends up to maxNotificationsPerBatch notifications in a single
+// multipart/mixed request. The returned error is only set when the batch call
+// itself failed; per-item failures are reported in the results. It also
+// returns how long the call waited for the outbound rate limit.
+func (gis *GoogleIndexingService) publishBatch(ctx context.Context, client *indexingClient, items []models.BatchIndexItem) ([]batchItemResult, time.Duration, error) {
+	if len(items) > maxNotificationsPerBatch {
+		return nil, 0, fmt.Errorf("batch cannot contain more than %d notifications", maxNotificationsPerBatch)
+	}
+
+	body, contentType, err := encodeBatchRequest(items)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode batch request: %v", err)
+	}
+
+	// Google counts every notification of a batch against the rate limit
+	queueWait, err := gis.outbound.wait(ctx, client.projectID, len(items))
+	if err != nil {
+		return nil, queueWait, err
+	}
+
+	// A batch is one outbound call, so it takes a single worker
+	var results []batchItemResult
+	err = gis.call(ctx, "batch", func(callCtx context.Context) error {
+		req, err := http.NewRequestWithContext(callCtx, http.MethodPost, gis.batchEndpoint, body)
+		if err != nil {
+			return fmt.Errorf("failed to create batch request: %v", err)
+		}
+		req.Header.Set("Content-Type", contentType)
+
+		results, err = doBatchRequest(client.httpClient, req, len(items))
+		return err
+	})
+
+	// Count answered parts like individual publish calls
+	for _, result := range results {
+		if result.metadata != nil || result.err != nil {
+			metrics.GoogleAPICalls.WithLabelValues("publish", resultCode(result.err)).Inc()
+			gis.quota.record(client, result.err)
+		}
+	}
+
+	return results, queueWait, err
+}
+
+// doBatchRequest sends a prepared batch request and decodes its response.
+func doBatchRequest(httpClient *http.Client, req *http.Request, count int) ([]batchItemResult, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("batch request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, &googleapi.Error{
+			Code:   resp.StatusCode,
+			Body:   string(data),
+			Header: resp.Header,
+		}
+	}
+
+	return decodeBatchResponse(resp, count)
+}
+
+// encodeBatchRequest builds the multipart/mixed body for a batch of publish calls.
+// Each part is identified by Content-ID <itemN>, where N is the 1-based index.
+func encodeBatchRequest(items []models.BatchIndexItem) (io.Reader, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for i, item := range items {
+		payload, err := json.Marshal(&indexing.UrlNotification{
+			Url:  item.URL,
+			Type: item.Type,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "application/http")
+		header.Set("Content-Transfer-Encoding", "binary")
+		header.Set("Content-ID", fmt.Sprintf("<item%d>", i+1))
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+
+		fmt.Fprintf(part, "POST %s HTTP/1.1\r\n", indexingPublishPath)
+		fmt.Fprintf(part, "Content-Type: application/json\r\n")
+		fmt.Fprintf(part, "Content-Length: %d\r\n\r\n", len(payload))
+		part.Write(payload)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return &body, "multipart/mixed; boundary=" + writer.Boundary(), nil
+}
+
+// decodeBatchResponse maps every part of a multipart/mixed batch response back
+// to the item it answers, using the <response-itemN> Content-ID.
+func decodeBatchResponse(resp *http.Response, count int) ([]batchItemResult, error) {
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid batch response content type: %v", err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("unexpected batch response content type %q", mediaType)
+	}
+
+	results := make([]batchItemResult, count)
+	reader := multipart.NewReader(resp.Body, params["boundary"])
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read batch response: %v", err)
+		}
+
+		index, ok := parseBatchContentID(part.Header.Get("Content-ID"))
+		if !ok || index >= count {
+			part.Close()
+			continue
+		}
+
+		results[index] = decodeBatchPart(part)
+		part.Close()
+	}
+
+	return results, nil
+}
+
+// decodeBatchPart parses the embedded HTTP response of a single batch part.
+func decodeBatchPart(part io.Reader) batchItemResult {
+	partResp, err := http.ReadResponse(bufio.NewReader(part), nil)
+	if err != nil {
+		return batchItemResult{err: fmt.Errorf("failed to parse batch part: %v", err)}
+	}
+	defer partResp.Body.Close()
+
+	data, err := io.ReadAll(partResp.Body)
+	if err != nil {
+		return batchItemResult{err: fmt.Errorf("failed to read batch part: %v", err)}
+	}
+
+	if partResp.StatusCode != http.StatusOK {
+		apiErr := &googleapi.Error{
+			Code:   partResp.StatusCode,
+			Body:   string(data),
+			Header: partResp.Header,
+		}
+
+		var errResp struct {
+			Error *googleapi.Error `json:"error"`
+		}
+		if json.Unmarshal(data, &errResp) == nil && errResp.Error != nil {
+			apiErr.Message = errResp.Error.Message
+			apiErr.Details = errResp.Error.Details
+		}
+
+		return batchItemResult{err: apiErr}
+	}
+
+	metadata := &indexing.PublishUrlNotificationResponse{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return batchItemResult{err: fmt.Errorf("failed to decode batch part: %v", err)}
+	}
+
+	return batchItemResult{metadata: metadata}
+}
+
+// parseBatchContentID turns "<response-item7>" into the 0-based index 6.
+func parseBatchContentID(id string) (int, bool) {
+	id = strings.Trim(strings.TrimSpace(id), "<>")
+	id = strings.TrimPrefix(id, "response-")
+	id = strings.TrimPrefix(id, "item")
+
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+
+	return n - 1, true
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/indexing/v3"
+
+	"google-indexing-api/internal/models"
+)
+
+// batchPart is one publish call of a batch request, as the fake server saw it.
+type batchPart struct {
+	contentID    string
+	notification indexing.UrlNotification
+}
+
+// batchAnswer is how the fake server answers a part. An empty body leaves
+// the part out of the response.
+type batchAnswer struct {
+	status     int
+	body       string
+	retryAfter string
+}
+
+// newBatchServer fakes the batch and publish endpoints. Batch requests are
+// decoded and answered part by part with answer, in the order it returns
+// them. Individual publishes are counted and always succeed.
+func newBatchServer(t *testing.T, answer func(parts []batchPart) ([]batchPart, map[string]batchAnswer), publishes *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/batch" {
+			publishes.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"urlNotificationMetadata":{"url":"x"}}`)
+			return
+		}
+
+		parts, err := readBatchRequest(r)
+		if err != nil {
+			t.Errorf("invalid batch request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		order, answers := answer(parts)
+
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for _, part := range order {
+			a, exists := answers[part.contentID]
+			if !exists || a.body == "" {
+				continue
+			}
+
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Type", "application/http")
+			header.Set("Content-ID", "<response-"+strings.Trim(part.contentID, "<>")+">")
+			pw, _ := writer.CreatePart(header)
+			fmt.Fprintf(pw, "HTTP/1.1 %d %s\r\nContent-Type: application/json\r\n", a.status, http.StatusText(a.status))
+			if a.retryAfter != "" {
+				fmt.Fprintf(pw, "Retry-After: %s\r\n", a.retryAfter)
+			}
+			fmt.Fprintf(pw, "\r\n%s", a.body)
+		}
+		writer.Close()
+
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+		w.Write(body.Bytes())
+	}))
+}
+
+// readBatchRequest decodes a request built by encodeBatchRequest.
+func readBatchRequest(r *http.Request) ([]batchPart, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []batchPart
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.ReadRequest(bufio.NewReader(part))
+		if err != nil {
+			return nil, err
+		}
+		if req.Method != http.MethodPost || req.URL.Path != indexingPublishPath {
+			return nil, fmt.Errorf("unexpected embedded request %s %s", req.Method, req.URL.Path)
+		}
+
+		p := batchPart{contentID: part.Header.Get("Content-ID")}
+		if err := json.NewDecoder(req.Body).Decode(&p.notification); err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+}
+
+// echoAnswers answers every part with a success naming its URL.
+func echoAnswers(parts []batchPart) map[string]batchAnswer {
+	answers := make(map[string]batchAnswer, len(parts))
+	for _, part := range parts {
+		answers[part.contentID] = batchAnswer{
+			status: http.StatusOK,
+			body:   fmt.Sprintf(`{"urlNotificationMetadata":{"url":%q}}`, part.notification.Url),
+		}
+	}
+	return answers
+}
+
+func TestPublishBatchOutOfOrderParts(t *testing.T) {
+	var publishes atomic.Int32
+	server := newBatchServer(t, func(parts []batchPart) ([]batchPart, map[string]batchAnswer) {
+		reversed := make([]batchPart, len(parts))
+		for i, part := range parts {
+			reversed[len(parts)-1-i] = part
+		}
+		return reversed, echoAnswers(parts)
+	}, &publishes)
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	client := addTestClient(t, gis, server, &testCredentials{email: "only@p1", project: "p1"})
+
+	items := testItems(5)
+	results, _, err := gis.publishBatch(context.Background(), client, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, result := range results {
+		if result.err != nil || result.metadata == nil {
+			t.Fatalf("item %d: got %v, want metadata", i, result.err)
+		}
+		if got := result.metadata.UrlNotificationMetadata.Url; got != items[i].URL {
+			t.Errorf("item %d was matched with the answer for %s", i, got)
+		}
+	}
+	if publishes.Load() != 0 {
+		t.Errorf("got %d individual publishes, want 0", publishes.Load())
+	}
+}
+
+func TestSubmitURLsBatchResubmitsMissingParts(t *testing.T) {
+	var publishes atomic.Int32
+	server := newBatchServer(t, func(parts []batchPart) ([]batchPart, map[string]batchAnswer) {
+		answers := echoAnswers(parts)
+		delete(answers, parts[1].contentID)
+		return parts, answers
+	}, &publishes)
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	credentials := &testCredentials{email: "only@p1", project: "p1"}
+	addTestClient(t, gis, server, credentials)
+
+	response, err := gis.SubmitURLsBatch(context.Background(), testItems(3), SingleAccount(credentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Statistics.Successful != 3 {
+		t.Errorf("got %d successful URLs, want 3", response.Statistics.Successful)
+	}
+	if publishes.Load() != 1 {
+		t.Errorf("got %d individual publishes, want 1 for the missing part", publishes.Load())
+	}
+	// The unanswered part does not count as an attempt
+	if attempts := response.Results[1].Attempts; attempts != 1 {
+		t.Errorf("missing part took %d attempts, want 1", attempts)
+	}
+}
+
+func TestSubmitURLsBatchPartError(t *testing.T) {
+	var publishes atomic.Int32
+	server := newBatchServer(t, func(parts []batchPart) ([]batchPart, map[string]batchAnswer) {
+		answers := echoAnswers(parts)
+		answers[parts[0].contentID] = batchAnswer{
+			status: http.StatusForbidden,
+			body:   `{"error":{"code":403,"message":"Permission denied. Failed to verify the URL ownership.","status":"PERMISSION_DENIED"}}`,
+		}
+		return parts, answers
+	}, &publishes)
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	credentials := &testCredentials{email: "only@p1", project: "p1"}
+	client := addTestClient(t, gis, server, credentials)
+
+	results, _, err := gis.publishBatch(context.Background(), client, testItems(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var apiErr *googleapi.Error
+	if !errors.As(results[0].err, &apiErr) {
+		t.Fatalf("got %v, want a *googleapi.Error", results[0].err)
+	}
+	if apiErr.Code != http.StatusForbidden || apiErr.Message != "Permission denied. Failed to verify the URL ownership." {
+		t.Errorf("got code %d and message %q from the error body", apiErr.Code, apiErr.Message)
+	}
+	if results[1].metadata == nil {
+		t.Errorf("other part got %v, want metadata", results[1].err)
+	}
+
+	response, err := gis.SubmitURLsBatch(context.Background(), testItems(2), SingleAccount(credentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := response.Results[0]
+	if failed.Success || failed.ErrorKind != models.ErrorKindGoogleAPI || failed.Attempts != 1 {
+		t.Errorf("got success %v, error kind %q after %d attempts, want a failed %q after 1", failed.Success, failed.ErrorKind, failed.Attempts, models.ErrorKindGoogleAPI)
+	}
+	if !response.Results[1].Success {
+		t.Errorf("other URL failed: %s", response.Results[1].Message)
+	}
+	// A permanent error is not resubmitted
+	if publishes.Load() != 0 {
+		t.Errorf("got %d individual publishes, want 0", publishes.Load())
+	}
+}
+
+func TestSubmitURLsBatchRetriedPartHonorsRetryAfter(t *testing.T) {
+	var publishes atomic.Int32
+	server := newBatchServer(t, func(parts []batchPart) ([]batchPart, map[string]batchAnswer) {
+		answers := echoAnswers(parts)
+		answers[parts[0].contentID] = batchAnswer{
+			status:     http.StatusTooManyRequests,
+			body:       `{"error":{"code":429,"message":"Too many requests","status":"RESOURCE_EXHAUSTED"}}`,
+			retryAfter: "1",
+		}
+		return parts, answers
+	}, &publishes)
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	credentials := &testCredentials{email: "only@p1", project: "p1"}
+	addTestClient(t, gis, server, credentials)
+
+	start := time.Now()
+	response, err := gis.SubmitURLsBatch(context.Background(), testItems(2), SingleAccount(credentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The retry policy backs off for 1ms; only the Retry-After explains a 1s wait
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("part was resubmitted after %s, want it to wait for Retry-After", elapsed)
+	}
+	if publishes.Load() != 1 {
+		t.Errorf("got %d individual publishes, want 1", publishes.Load())
+	}
+	if retried := response.Results[0]; !retried.Success || retried.Attempts != 2 {
+		t.Errorf("got success %v after %d attempts, want success after 2", retried.Success, retried.Attempts)
+	}
+}
+
+func TestSubmitURLsBatchNonMultipartResponse(t *testing.T) {
+	var batches, publishes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/batch" {
+			batches.Add(1)
+			fmt.Fprint(w, `{"kind":"not a batch response"}`)
+			return
+		}
+
+		publishes.Add(1)
+		fmt.Fprint(w, `{"urlNotificationMetadata":{"url":"x"}}`)
+	}))
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	credentials := &testCredentials{email: "only@p1", project: "p1"}
+	addTestClient(t, gis, server, credentials)
+
+	response, err := gis.SubmitURLsBatch(context.Background(), testItems(4), SingleAccount(credentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if batches.Load() != 1 {
+		t.Errorf("got %d batch calls, want 1", batches.Load())
+	}
+	if publishes.Load() != 4 {
+		t.Errorf("got %d individual publishes, want 4", publishes.Load())
+	}
+	if response.Statistics.Successful != 4 {
+		t.Errorf("got %d successful URLs, want 4", response.Statistics.Successful)
+	}
+}
+
+func TestParseBatchContentID(t *testing.T) {
+	tests := []struct {
+		id    string
+		index int
+		ok    bool
+	}{
+		{"<response-item1>", 0, true},
+		{" <response-item12> ", 11, true},
+		{"<item3>", 2, true},
+		{"response-item7", 6, true},
+		{"<response-item0>", 0, false},
+		{"<response-itemx>", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		index, ok := parseBatchContentID(tt.id)
+		if index != tt.index || ok != tt.ok {
+			t.Errorf("parseBatchContentID(%q) = %d, %v, want %d, %v", tt.id, index, ok, tt.index, tt.ok)
+		}
+	}
+}
@@ -3,49 +3,111 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/indexing/v3"
 	"google.golang.org/api/option"
+	"google.golang.org/api/searchconsole/v1"
+	htransport "google.golang.org/api/transport/http"
 
+	"google-indexing-api/internal/config"
+	"google-indexing-api/internal/metrics"
 	"google-indexing-api/internal/models"
+	"google-indexing-api/internal/store"
 )
 
 type GoogleIndexingService struct {
 	defaultService *indexing.Service
 	logger         *logrus.Logger
-	serviceCache   map[string]*indexing.Service
-	cacheMutex     sync.RWMutex
+	history        store.Store
+	serviceCache   *serviceCache
+	batchEndpoint  string
+	workers        *workerPool
+	retry          retryPolicy
+	requestTimeout time.Duration
+	quota          *quotaTracker
+	outbound       *outboundLimiter
+	ownership      *ownershipChecker
 }
 
-func NewGoogleIndexingService(logger *logrus.Logger) (*GoogleIndexingService, error) {
+// indexingClient bundles the generated API client with the authenticated HTTP
+// client it uses, so batch requests can share the same credentials. sites is
+// only set when the ownership check is enabled.
+type indexingClient struct {
+	service     *indexing.Service
+	httpClient  *http.Client
+	clientEmail string
+	projectID   string
+	sites       *searchconsole.Service
+}
+
+// NewGoogleIndexingService creates the service. Every notification sent is
+// recorded in history, which may be nil to keep no history.
+func NewGoogleIndexingService(history store.Store, logger *logrus.Logger) (*GoogleIndexingService, error) {
+	cfg := config.GetConfig()
+
+	requestTimeout := time.Duration(cfg.Performance.RequestTimeoutSeconds) * time.Second
+	if requestTimeout <= 0 {
+		requestTimeout = 30 * time.Second
+	}
+
+	quota, err := newQuotaTracker(cfg.Quota.DailyLimit, cfg.Quota.ProjectLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	if history != nil {
+		if err := quota.seed(context.Background(), history); err != nil {
+			logger.WithError(err).Warn("Failed to load today's quota usage from history")
+		}
+	}
+
+	var ownership *ownershipChecker
+	if cfg.Ownership.CheckEnabled {
+		ownership = newOwnershipChecker(cfg.Ownership.SearchConsoleEndpoint, time.Duration(cfg.Ownership.CacheTTLMinutes)*time.Minute)
+	}
+
 	return &GoogleIndexingService{
 		defaultService: nil, // No default service account
 		logger:         logger,
-		serviceCache:   make(map[string]*indexing.Service),
-		cacheMutex:     sync.RWMutex{},
+		history:        history,
+		serviceCache:   newServiceCache(time.Duration(cfg.Performance.CacheTTLMinutes)*time.Minute, cfg.Performance.CacheMaxEntries),
+		batchEndpoint:  indexingBatchEndpoint,
+		workers:        newWorkerPool(cfg.Performance.MaxConcurrentRequests),
+		retry:          newRetryPolicy(cfg.Performance.MaxRetryAttempts, time.Duration(cfg.Performance.RetryDelaySeconds)*time.Second),
+		requestTimeout: requestTimeout,
+		quota:          quota,
+		outbound:       newOutboundLimiter(cfg.Outbound.PerMinute, cfg.Outbound.PerSecond),
+		ownership:      ownership,
 	}, nil
 }
 
-func (gis *GoogleIndexingService) getIndexingService(ctx context.Context, serviceAccount *models.ServiceAccountCredentials) (*indexing.Service, error) {
+func (gis *GoogleIndexingService) getIndexingService(ctx context.Context, credentials Credentials) (*indexingClient, error) {
 	// Service account is now required
-	if serviceAccount == nil {
+	if credentials == nil {
 		return nil, fmt.Errorf("service account is required")
 	}
 
-	// Create cache key from service account
-	cacheKey := serviceAccount.ClientEmail
+	// Key the cache on the exact credentials sent, not just the account, so a
+	// rotated or replaced key never reuses a client built from another key
+	cacheKey := credentials.Fingerprint()
 
 	// Check cache first
-	gis.cacheMutex.RLock()
-	if cachedService, exists := gis.serviceCache[cacheKey]; exists {
-		gis.cacheMutex.RUnlock()
+	if cachedService, exists := gis.serviceCache.get(cacheKey); exists {
 		return cachedService, nil
 	}
-	gis.cacheMutex.RUnlock()
+
+	serviceAccount, err := credentials.serviceAccount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load service account credentials: %v", err)
+	}
 
 	// Create new service from provided credentials
 	credentialsJSON, err := json.Marshal(serviceAccount)
@@ -53,88 +115,386 @@ func (gis *GoogleIndexingService) getIndexingService(ctx context.Context, servic
 		return nil, fmt.Errorf("failed to marshal service account credentials: %v", err)
 	}
 
-	service, err := indexing.NewService(ctx, option.WithCredentialsJSON(credentialsJSON))
+	// Cached clients outlive the request that created them, so they must not
+	// be bound to its context.
+	scopes := []string{indexing.IndexingScope}
+	if gis.ownership != nil {
+		scopes = append(scopes, searchconsole.WebmastersReadonlyScope)
+	}
+
+	httpClient, _, err := htransport.NewClient(context.Background(),
+		option.WithCredentialsJSON(credentialsJSON),
+		option.WithScopes(scopes...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client with provided credentials: %v", err)
+	}
+
+	service, err := indexing.NewService(context.Background(), option.WithHTTPClient(httpClient))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create indexing service with provided credentials: %v", err)
 	}
 
+	client := &indexingClient{
+		service:     service,
+		httpClient:  httpClient,
+		clientEmail: serviceAccount.ClientEmail,
+		projectID:   serviceAccount.ProjectID,
+	}
+
+	if gis.ownership != nil {
+		opts := []option.ClientOption{option.WithHTTPClient(httpClient)}
+		if gis.ownership.endpoint != "" {
+			opts = append(opts, option.WithEndpoint(gis.ownership.endpoint))
+		}
+
+		client.sites, err = searchconsole.NewService(context.Background(), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Search Console service with provided credentials: %v", err)
+		}
+	}
+
 	// Cache the service
-	gis.cacheMutex.Lock()
-	gis.serviceCache[cacheKey] = service
-	gis.cacheMutex.Unlock()
+	gis.serviceCache.add(cacheKey, serviceAccount.ClientEmail, client)
 
 	gis.logger.WithField("service_account", serviceAccount.ClientEmail).Info("Created new indexing service")
 
-	return service, nil
+	return client, nil
 }
 
-func (gis *GoogleIndexingService) SubmitURL(ctx context.Context, url string, serviceAccount *models.ServiceAccountCredentials) (*models.IndexResponse, error) {
-	gis.logger.WithField("url", url).Info("Submitting URL to Google Indexing API")
+// SubmitURL publishes a single notification. With a pool, the URL moves to
+// the next member when a member's quota is exhausted or its credentials are
+// unusable.
+func (gis *GoogleIndexingService) SubmitURL(ctx context.Context, url string, notificationType string, accounts *Accounts) (*models.IndexResponse, error) {
+	if notificationType == "" {
+		notificationType = models.NotificationTypeUpdated
+	}
 
-	service, err := gis.getIndexingService(ctx, serviceAccount)
-	if err != nil {
-		return &models.IndexResponse{
-			Success: false,
-			Message: fmt.Sprintf("Failed to get indexing service: %v", err),
-			URL:     url,
-		}, err
+	gis.logger.WithFields(logrus.Fields{
+		"url":  url,
+		"type": notificationType,
+		"pool": accounts.pool,
+	}).Info("Submitting URL to Google Indexing API")
+
+	item := models.BatchIndexItem{URL: url, Type: notificationType}
+	excluded := make([]bool, len(accounts.members))
+	eligible := gis.eligible(ctx, accounts)
+
+	// Timing covers every member tried
+	start := time.Now()
+	var queueWait time.Duration
+
+	for {
+		member := accounts.assign([]models.BatchIndexItem{item}, excluded, gis.quota, eligible)[0]
+		if member == -1 {
+			response, err := accounts.notOwnedResponse(item)
+			gis.logger.WithError(err).WithField("pool", accounts.pool).Warn("Rejected URL the service account may not submit")
+			return response, err
+		}
+		excluded[member] = true
+		credentials := accounts.members[member]
+
+		failover := accounts.hasMemberFor(-1, url, excluded, eligible)
+		callCtx := ctx
+		if failover {
+			callCtx = withFailover(ctx)
+		}
+
+		var response *models.IndexResponse
+		client, err := gis.getIndexingService(ctx, credentials)
+		if err != nil {
+			response = &models.IndexResponse{
+				Success:        false,
+				Message:        fmt.Sprintf("Failed to get indexing service: %v", err),
+				URL:            url,
+				Type:           notificationType,
+				ServiceAccount: credentials.ClientEmail(),
+				ErrorKind:      models.ErrorKindCredentials,
+			}
+		} else {
+			response, err = gis.publish(callCtx, client, item, 0, false)
+		}
+
+		if response.Timing != nil {
+			queueWait += time.Duration(response.Timing.QueueWaitMs) * time.Millisecond
+		}
+
+		if err != nil && failover && shouldFailOver(*response) {
+			gis.logger.WithError(err).WithFields(logrus.Fields{
+				"pool":            accounts.pool,
+				"service_account": credentials.ClientEmail(),
+			}).Warn("Moving URL to another pool member")
+			continue
+		}
+
+		response.Timing = newTiming(queueWait, time.Since(start))
+
+		return response, err
 	}
+}
 
+// publish sends a single notification, retrying transient failures.
+// priorAttempts counts attempts already made for the item in a batch call.
+// prepaid means the outbound rate limit was already charged for the first
+// attempt, by a batch call that never reached Google for the item.
+func (gis *GoogleIndexingService) publish(ctx context.Context, client *indexingClient, item models.BatchIndexItem, priorAttempts int, prepaid bool) (*models.IndexResponse, error) {
 	urlNotification := &indexing.UrlNotification{
-		Url:  url,
-		Type: "URL_UPDATED",
+		Url:  item.URL,
+		Type: item.Type,
+	}
+
+	start := time.Now()
+	throttle := func(ctx context.Context) (time.Duration, error) {
+		if prepaid {
+			prepaid = false
+			return 0, nil
+		}
+		return gis.outbound.wait(ctx, client.projectID, 1)
 	}
 
-	call := service.UrlNotifications.Publish(urlNotification)
-	resp, err := call.Do()
+	var resp *indexing.PublishUrlNotificationResponse
+	attempts, queueWait, err := gis.withRetry(ctx, "publish", priorAttempts, throttle, func(callCtx context.Context) error {
+		var callErr error
+		resp, callErr = client.service.UrlNotifications.Publish(urlNotification).Context(callCtx).Do()
+		gis.quota.record(client, callErr)
+		return callErr
+	})
 	if err != nil {
-		gis.logger.WithError(err).WithField("url", url).Error("Failed to submit URL")
+		gis.logger.WithError(err).WithField("url", item.URL).Error("Failed to submit URL")
+		gis.recordNotification(ctx, client, item, attempts, nil, err)
 		return &models.IndexResponse{
-			Success: false,
-			Message: fmt.Sprintf("Failed to submit URL: %v", err),
-			URL:     url,
+			Success:        false,
+			Message:        fmt.Sprintf("Failed to submit URL: %v", err),
+			URL:            item.URL,
+			Type:           item.Type,
+			ServiceAccount: client.clientEmail,
+			Attempts:       attempts,
+			ErrorKind:      errorKind(err),
+			Timing:         newTiming(queueWait, time.Since(start)),
 		}, err
 	}
 
-	gis.logger.WithField("url", url).WithField("response", resp).Info("URL submitted successfully")
+	gis.logger.WithField("url", item.URL).WithField("response", resp).Info("URL submitted successfully")
+	gis.recordNotification(ctx, client, item, attempts, resp, nil)
 
 	return &models.IndexResponse{
-		Success: true,
-		Message: "URL submitted successfully",
-		URL:     url,
+		Success:        true,
+		Message:        "URL submitted successfully",
+		URL:            item.URL,
+		Type:           item.Type,
+		ServiceAccount: client.clientEmail,
+		Attempts:       attempts,
+		Timing:         newTiming(queueWait, time.Since(start)),
 	}, nil
 }
 
-func (gis *GoogleIndexingService) SubmitURLsBatch(ctx context.Context, urls []string, serviceAccount *models.ServiceAccountCredentials) (*models.BatchIndexResponse, error) {
-	gis.logger.WithField("count", len(urls)).Info("Submitting batch URLs to Google Indexing API")
+func (gis *GoogleIndexingService) SubmitURLsBatch(ctx context.Context, items []models.BatchIndexItem, accounts *Accounts) (*models.BatchIndexResponse, error) {
+	return gis.SubmitURLsBatchWithProgress(ctx, items, accounts, nil)
+}
+
+// SubmitURLsBatchWithProgress works like SubmitURLsBatch and also calls
+// progress whenever a part of the batch is done, with the offset of that part
+// in items and its final results. progress may be called concurrently.
+func (gis *GoogleIndexingService) SubmitURLsBatchWithProgress(ctx context.Context, items []models.BatchIndexItem, accounts *Accounts, progress func(offset int, results []models.IndexResponse)) (*models.BatchIndexResponse, error) {
+	gis.logger.WithFields(logrus.Fields{
+		"count": len(items),
+		"pool":  accounts.pool,
+	}).Info("Submitting batch URLs to Google Indexing API")
 
+	start := time.Now()
+	results := make([]models.IndexResponse, len(items))
+
+	// Pack notifications into as few multipart batch calls as possible. With
+	// a pool, each window is spread over the members
 	var wg sync.WaitGroup
-	results := make([]models.IndexResponse, len(urls))
+	for offset := 0; offset < len(items); offset += maxNotificationsPerBatch {
+		end := offset + maxNotificationsPerBatch
+		if end > len(items) {
+			end = len(items)
+		}
 
-	// Use goroutines for concurrent processing
-	for i, url := range urls {
 		wg.Add(1)
-		go func(index int, u string) {
+		go func(offset int, chunk []models.BatchIndexItem, out []models.IndexResponse) {
 			defer wg.Done()
+			gis.submitWindow(ctx, accounts, chunk, out)
+			if progress != nil {
+				progress(offset, out)
+			}
+		}(offset, items[offset:end], results[offset:end])
+	}
 
-			result, err := gis.SubmitURL(ctx, u, serviceAccount)
-			if err != nil {
-				results[index] = models.IndexResponse{
-					Success: false,
-					Message: fmt.Sprintf("Failed to submit URL: %v", err),
-					URL:     u,
-				}
-			} else {
-				results[index] = *result
+	wg.Wait()
+
+	response := newBatchIndexResponse(gis.logger, results)
+	response.Timing = batchTiming(results, time.Since(start))
+
+	return response, nil
+}
+
+// submitChunk publishes a chunk of notifications through the batch endpoint and
+// writes the per-item outcome into out. If the batch call itself fails, every
+// item is submitted individually instead. Items Google left unanswered, or
+// answered with a transient error, are resubmitted individually as well.
+func (gis *GoogleIndexingService) submitChunk(ctx context.Context, client *indexingClient, chunk []models.BatchIndexItem, out []models.IndexResponse) {
+	start := time.Now()
+
+	batchResults, batchWait, err := gis.publishBatch(ctx, client, chunk)
+	if err != nil {
+		gis.logger.WithError(err).WithField("count", len(chunk)).Warn("Batch request failed, falling back to individual requests")
+		gis.submitIndividually(ctx, client, chunk, out, make([]int, len(chunk)), make([]error, len(chunk)))
+		addBatchTiming(out, batchWait, start)
+		return
+	}
+
+	var resubmit []int
+	var priorAttempts []int
+	var priorErrors []error
+	for i, result := range batchResults {
+		item := chunk[i]
+
+		switch {
+		case result.err != nil && gis.retry.maxAttempts > 1 && isRetryableError(ctx, result.err):
+			resubmit = append(resubmit, i)
+			priorAttempts = append(priorAttempts, 1)
+			priorErrors = append(priorErrors, result.err)
+			metrics.GoogleAPIRetries.WithLabelValues("publish").Inc()
+		case result.err != nil:
+			gis.logger.WithError(result.err).WithField("url", item.URL).Error("Failed to submit URL")
+			gis.recordNotification(ctx, client, item, 1, nil, result.err)
+			out[i] = models.IndexResponse{
+				Success:        false,
+				Message:        fmt.Sprintf("Failed to submit URL: %v", result.err),
+				URL:            item.URL,
+				Type:           item.Type,
+				ServiceAccount: client.clientEmail,
+				Attempts:       1,
+				ErrorKind:      errorKind(result.err),
+				Timing:         newTiming(batchWait, time.Since(start)),
 			}
-		}(i, url)
+		case result.metadata == nil:
+			// Never answered, so it does not count as an attempt
+			resubmit = append(resubmit, i)
+			priorAttempts = append(priorAttempts, 0)
+			priorErrors = append(priorErrors, nil)
+		default:
+			gis.logger.WithField("url", item.URL).WithField("response", result.metadata).Info("URL submitted successfully")
+			gis.recordNotification(ctx, client, item, 1, result.metadata, nil)
+			out[i] = models.IndexResponse{
+				Success:        true,
+				Message:        "URL submitted successfully",
+				URL:            item.URL,
+				Type:           item.Type,
+				ServiceAccount: client.clientEmail,
+				Attempts:       1,
+				Timing:         newTiming(batchWait, time.Since(start)),
+			}
+		}
+	}
+
+	if len(resubmit) > 0 {
+		gis.logger.WithField("count", len(resubmit)).Warn("Resubmitting unanswered or failed batch items individually")
+
+		retry := make([]models.BatchIndexItem, len(resubmit))
+		retryOut := make([]models.IndexResponse, len(resubmit))
+		for i, index := range resubmit {
+			retry[i] = chunk[index]
+		}
+
+		gis.submitIndividually(ctx, client, retry, retryOut, priorAttempts, priorErrors)
+		addBatchTiming(retryOut, batchWait, start)
+
+		for i, index := range resubmit {
+			out[index] = retryOut[i]
+		}
+	}
+}
+
+// submitIndividually publishes each item with its own API call. It is only
+// used for items of a batch call, which already charged the outbound rate
+// limit for them; items without prior attempts never reached Google, so their
+// first attempt is not charged again. Items that failed inside the batch with
+// priorErrors back off first, honoring a Retry-After Google sent.
+func (gis *GoogleIndexingService) submitIndividually(ctx context.Context, client *indexingClient, items []models.BatchIndexItem, out []models.IndexResponse, priorAttempts []int, priorErrors []error) {
+	var wg sync.WaitGroup
+
+	for i, item := range items {
+		wg.Add(1)
+		go func(index int, it models.BatchIndexItem) {
+			defer wg.Done()
+
+			if err := priorErrors[index]; err != nil {
+				delay := gis.retry.delay(priorAttempts[index], err)
+				gis.logger.WithError(err).WithFields(logrus.Fields{
+					"url":   it.URL,
+					"delay": delay.String(),
+				}).Warn("Batch item failed, retrying individually")
+				// A canceled wait fails the publish below as canceled
+				sleep(ctx, delay)
+			}
+
+			result, _ := gis.publish(ctx, client, it, priorAttempts[index], priorAttempts[index] == 0)
+			out[index] = *result
+		}(i, item)
 	}
 
 	wg.Wait()
+}
 
+// recordNotification adds a notification to the history. Notifications that
+// were canceled before reaching Google are not recorded. Failing to record is
+// logged but does not fail the submission.
+func (gis *GoogleIndexingService) recordNotification(ctx context.Context, client *indexingClient, item models.BatchIndexItem, attempts int, resp *indexing.PublishUrlNotificationResponse, err error) {
+	if gis.history == nil || errorKind(err) == models.ErrorKindCanceled {
+		return
+	}
+
+	record := models.NotificationRecord{
+		URL:            item.URL,
+		Type:           item.Type,
+		ServiceAccount: client.clientEmail,
+		ProjectID:      client.projectID,
+		JobID:          jobIDFromContext(ctx),
+		Success:        err == nil,
+		NotifyTime:     time.Now().UTC().Format(time.RFC3339Nano),
+		Attempts:       attempts,
+	}
+
+	if resp != nil && resp.UrlNotificationMetadata != nil {
+		if data, marshalErr := json.Marshal(resp.UrlNotificationMetadata); marshalErr == nil {
+			record.GoogleResponse = data
+		}
+
+		// Prefer the time Google recorded for this notification
+		latest := resp.UrlNotificationMetadata.LatestUpdate
+		if item.Type == models.NotificationTypeDeleted {
+			latest = resp.UrlNotificationMetadata.LatestRemove
+		}
+		if latest != nil && latest.NotifyTime != "" {
+			record.NotifyTime = latest.NotifyTime
+		}
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+		record.ErrorKind = errorKind(err)
+
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && json.Valid([]byte(apiErr.Body)) {
+			record.GoogleResponse = json.RawMessage(apiErr.Body)
+		}
+	}
+
+	// The submission may already be done, but the record must still be kept
+	if _, storeErr := gis.history.RecordNotification(context.WithoutCancel(ctx), record); storeErr != nil {
+		gis.logger.WithError(storeErr).WithField("url", item.URL).Warn("Failed to record notification")
+	}
+}
+
+// newBatchIndexResponse summarises per-URL results into a batch response.
+func newBatchIndexResponse(logger *logrus.Logger, results []models.IndexResponse) *models.BatchIndexResponse {
 	// Calculate statistics
 	stats := models.BatchIndexResponseStats{
-		Total: len(urls),
+		Total: len(results),
 	}
 
 	for _, result := range results {
@@ -152,66 +512,243 @@ func (gis *GoogleIndexingService) SubmitURLsBatch(ctx context.Context, urls []st
 		Statistics: stats,
 	}
 
-	gis.logger.WithField("statistics", stats).Info("Batch URL submission completed")
+	logger.WithField("statistics", stats).Info("Batch URL submission completed")
 
-	return response, nil
+	return response
 }
 
-func (gis *GoogleIndexingService) GetURLStatus(ctx context.Context, url string, serviceAccount *models.ServiceAccountCredentials) (*models.StatusResponse, error) {
+func newTiming(queueWait time.Duration, total time.Duration) *models.Timing {
+	return &models.Timing{
+		QueueWaitMs: queueWait.Milliseconds(),
+		TotalMs:     total.Milliseconds(),
+	}
+}
+
+// addBatchTiming adds the wait of a batch call to results that were then
+// submitted individually, and measures their total from the batch start.
+func addBatchTiming(results []models.IndexResponse, batchWait time.Duration, start time.Time) {
+	total := time.Since(start).Milliseconds()
+
+	for i := range results {
+		if results[i].Timing == nil {
+			results[i].Timing = &models.Timing{}
+		}
+		results[i].Timing.QueueWaitMs += batchWait.Milliseconds()
+		results[i].Timing.TotalMs = total
+	}
+}
+
+// batchTiming reports the longest queue wait of any result, next to the total
+// time of the batch.
+func batchTiming(results []models.IndexResponse, total time.Duration) *models.Timing {
+	timing := &models.Timing{TotalMs: total.Milliseconds()}
+
+	for _, result := range results {
+		if result.Timing != nil && result.Timing.QueueWaitMs > timing.QueueWaitMs {
+			timing.QueueWaitMs = result.Timing.QueueWaitMs
+		}
+	}
+
+	return timing
+}
+
+func (gis *GoogleIndexingService) GetURLStatus(ctx context.Context, url string, credentials Credentials) (*models.StatusResponse, error) {
 	gis.logger.WithField("url", url).Info("Getting URL status from Google Indexing API")
 
-	service, err := gis.getIndexingService(ctx, serviceAccount)
+	client, err := gis.getIndexingService(ctx, credentials)
 	if err != nil {
 		return &models.StatusResponse{
-			URL:    url,
-			Status: "error",
+			URL:       url,
+			Status:    models.StatusError,
+			ErrorKind: models.ErrorKindCredentials,
 		}, err
 	}
 
-	call := service.UrlNotifications.GetMetadata()
-	call.Url(url)
-
-	resp, err := call.Do()
+	var resp *indexing.UrlNotificationMetadata
+	_, _, err = gis.withRetry(ctx, "getMetadata", 0, nil, func(callCtx context.Context) error {
+		var callErr error
+		resp, callErr = client.service.UrlNotifications.GetMetadata().Url(url).Context(callCtx).Do()
+		return callErr
+	})
 	if err != nil {
+		// Google answers 404 for URLs it has never been notified about
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return &models.StatusResponse{
+				URL:    url,
+				Status: models.StatusNotNotified,
+			}, nil
+		}
+
 		gis.logger.WithError(err).WithField("url", url).Error("Failed to get URL status")
 		return &models.StatusResponse{
-			URL:    url,
-			Status: "error",
+			URL:       url,
+			Status:    models.StatusError,
+			ErrorKind: errorKind(err),
 		}, err
 	}
 
-	status := "unknown"
-	lastUpdated := ""
+	return newStatusResponse(url, resp), nil
+}
+
+// GetURLStatusBatch looks up the metadata of many URLs. Lookups share the
+// service worker pool, and results keep the input order.
+func (gis *GoogleIndexingService) GetURLStatusBatch(ctx context.Context, urls []string, credentials Credentials) (*models.BatchStatusResponse, error) {
+	gis.logger.WithField("count", len(urls)).Info("Getting batch URL status from Google Indexing API")
+
+	results := make([]models.StatusResponse, len(urls))
+
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func(index int, u string) {
+			defer wg.Done()
 
-	if resp.LatestUpdate != nil {
-		status = resp.LatestUpdate.Type
-		lastUpdated = resp.LatestUpdate.NotifyTime
+			result, err := gis.GetURLStatus(ctx, u, credentials)
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results[index] = *result
+		}(i, url)
 	}
 
-	return &models.StatusResponse{
-		URL:         url,
-		Status:      status,
-		LastUpdated: lastUpdated,
-	}, nil
+	wg.Wait()
+
+	stats := models.BatchStatusResponseStats{
+		Total: len(results),
+	}
+
+	for _, result := range results {
+		switch result.Status {
+		case models.NotificationTypeUpdated:
+			stats.Updated++
+		case models.NotificationTypeDeleted:
+			stats.Removed++
+		case models.StatusNotNotified:
+			stats.NeverNotified++
+		default:
+			stats.Errored++
+		}
+	}
+
+	response := &models.BatchStatusResponse{
+		Success: stats.Errored == 0,
+		Message: fmt.Sprintf("Checked %d URLs: %d updated, %d removed, %d never notified, %d errored",
+			stats.Total, stats.Updated, stats.Removed, stats.NeverNotified, stats.Errored),
+		Results:    results,
+		Statistics: stats,
+	}
+
+	gis.logger.WithField("statistics", stats).Info("Batch URL status lookup completed")
+
+	return response, nil
+}
+
+// newStatusResponse converts Google's metadata into a StatusResponse, taking
+// the overall status from whichever notification Google received last.
+func newStatusResponse(url string, metadata *indexing.UrlNotificationMetadata) *models.StatusResponse {
+	response := &models.StatusResponse{
+		URL:          url,
+		Status:       models.StatusNotNotified,
+		LatestUpdate: newNotificationInfo(metadata.LatestUpdate),
+		LatestRemove: newNotificationInfo(metadata.LatestRemove),
+	}
+
+	latest := response.LatestUpdate
+	if latest == nil || (response.LatestRemove != nil && notifiedAfter(response.LatestRemove, latest)) {
+		latest = response.LatestRemove
+	}
+
+	if latest != nil {
+		response.Status = latest.Type
+		response.LastUpdated = latest.NotifyTime
+	}
+
+	return response
+}
+
+func newNotificationInfo(notification *indexing.UrlNotification) *models.NotificationInfo {
+	if notification == nil {
+		return nil
+	}
+
+	return &models.NotificationInfo{
+		URL:        notification.Url,
+		Type:       notification.Type,
+		NotifyTime: notification.NotifyTime,
+	}
+}
+
+// notifiedAfter reports whether a was sent after b.
+func notifiedAfter(a, b *models.NotificationInfo) bool {
+	aTime, errA := time.Parse(time.RFC3339Nano, a.NotifyTime)
+	bTime, errB := time.Parse(time.RFC3339Nano, b.NotifyTime)
+	if errA != nil || errB != nil {
+		return a.NotifyTime > b.NotifyTime
+	}
+
+	return aTime.After(bTime)
+}
+
+// Collectors returns Prometheus collectors reading the service cache and the
+// worker pool. Register them once per service.
+func (gis *GoogleIndexingService) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "google_indexing",
+			Name:      "cache_hits_total",
+			Help:      "Service cache lookups that found a client.",
+		}, func() float64 {
+			hits, _ := gis.serviceCache.counters()
+			return float64(hits)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "google_indexing",
+			Name:      "cache_misses_total",
+			Help:      "Service cache lookups that had to build a client.",
+		}, func() float64 {
+			_, misses := gis.serviceCache.counters()
+			return float64(misses)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "google_indexing",
+			Name:      "workers_in_flight",
+			Help:      "Google API calls currently running on the worker pool.",
+		}, func() float64 {
+			return float64(gis.workers.InFlight())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "google_indexing",
+			Name:      "workers",
+			Help:      "Size of the worker pool (MAX_CONCURRENT_REQUESTS).",
+		}, func() float64 {
+			return float64(gis.workers.Size())
+		}),
+	}
+}
+
+// CheckQuota returns a *QuotaExceededError if publishing count URLs with
+// accounts would clearly go over the daily quota left in their projects. It
+// does not reserve quota, so concurrent requests may still overshoot slightly.
+func (gis *GoogleIndexingService) CheckQuota(accounts *Accounts, count int) error {
+	return gis.quota.check(accounts, count)
+}
+
+// GetQuota returns publish quota usage for the current quota day.
+func (gis *GoogleIndexingService) GetQuota() *models.QuotaResponse {
+	return gis.quota.usageReport()
 }
 
 // ClearCache clears the service cache (useful for cleanup)
 func (gis *GoogleIndexingService) ClearCache() {
-	gis.cacheMutex.Lock()
-	defer gis.cacheMutex.Unlock()
-
-	gis.serviceCache = make(map[string]*indexing.Service)
+	gis.serviceCache.clear()
 	gis.logger.Info("Service cache cleared")
 }
 
 // GetCacheStats returns cache statistics
-func (gis *GoogleIndexingService) GetCacheStats() map[string]interface{} {
-	gis.cacheMutex.RLock()
-	defer gis.cacheMutex.RUnlock()
+func (gis *GoogleIndexingService) GetCacheStats() models.CacheStats {
+	stats := gis.serviceCache.stats()
+	stats.HasDefault = false // No default service account
 
-	return map[string]interface{}{
-		"cached_services": len(gis.serviceCache),
-		"has_default":     false, // No default service account
-		"timestamp":       time.Now().UTC().Format(time.RFC3339),
-	}
+	return stats
 }
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google-indexing-api/internal/models"
+)
+
+func TestGetURLStatusNotNotified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.URL.RawQuery, "unknown") {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND"}}`)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"error":{"code":403,"message":"Permission denied","status":"PERMISSION_DENIED"}}`)
+	}))
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	credentials := &testCredentials{email: "only@p1", project: "p1"}
+	addTestClient(t, gis, server, credentials)
+
+	status, err := gis.GetURLStatus(context.Background(), "https://example.com/unknown", credentials)
+	if err != nil || status.Status != models.StatusNotNotified {
+		t.Errorf("got status %q (%v), want %q", status.Status, err, models.StatusNotNotified)
+	}
+
+	status, _ = gis.GetURLStatus(context.Background(), "https://example.com/denied", credentials)
+	if status.Status != models.StatusError {
+		t.Errorf("got status %q for a 403, want %q", status.Status, models.StatusError)
+	}
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/internal/store"
+)
+
+// ErrJobNotFound is returned for unknown or expired job IDs.
+var ErrJobNotFound = errors.New("job not found")
+
+// jobEvictionInterval is how often finished jobs past the retention period
+// are forgotten, so an idle server does not keep their results.
+const jobEvictionInterval = time.Minute
+
+// JobManager runs batch submissions in the background. Jobs are detached from
+// the HTTP request that created them, so a client can disconnect and poll for
+// the results later.
+//
+// Job state is saved to the history store as it changes, so jobs can still be
+// read after a restart. Jobs cannot be resumed: credentials are not persisted
+// with them, so unfinished jobs are marked interrupted on startup.
+type JobManager struct {
+	service   *GoogleIndexingService
+	history   store.Store
+	logger    *logrus.Logger
+	retention time.Duration
+	jobs      map[string]*job
+	mutex     sync.Mutex
+	running   sync.WaitGroup
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+type job struct {
+	id         string
+	apiKeyID   string
+	items      []models.BatchIndexItem
+	accounts   *Accounts
+	status     string
+	results    []models.IndexResponse
+	done       []bool
+	progress   models.JobProgress
+	createdAt  time.Time
+	startedAt  time.Time
+	finishedAt time.Time
+	cancel     context.CancelFunc
+
+	// saveMutex orders saves of the same job, so an older snapshot never
+	// overwrites a newer one
+	saveMutex sync.Mutex
+}
+
+// jobIDKey is the context key carrying the ID of the job a call belongs to.
+type jobIDKey struct{}
+
+func jobIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(jobIDKey{}).(string)
+	return id
+}
+
+// NewJobManager creates a job manager. Finished jobs are forgotten once they
+// are older than retention. history may be nil to keep jobs in memory only.
+func NewJobManager(service *GoogleIndexingService, history store.Store, retention time.Duration, logger *logrus.Logger) *JobManager {
+	jm := &JobManager{
+		service:   service,
+		history:   history,
+		logger:    logger,
+		retention: retention,
+		jobs:      make(map[string]*job),
+		stop:      make(chan struct{}),
+	}
+
+	jm.recoverStoredJobs()
+	go jm.evictExpired()
+
+	return jm
+}
+
+// evictExpired forgets expired jobs every jobEvictionInterval until Shutdown.
+func (jm *JobManager) evictExpired() {
+	ticker := time.NewTicker(jobEvictionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			jm.mutex.Lock()
+			jm.removeExpired(now)
+			jm.mutex.Unlock()
+		case <-jm.stop:
+			return
+		}
+	}
+}
+
+// Submit starts a job submitting items and returns it in the queued state.
+// apiKeyID records who created the job.
+func (jm *JobManager) Submit(items []models.BatchIndexItem, accounts *Accounts, apiKeyID string) (*models.JobResponse, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	// Not derived from the request context: the job outlives the request
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = context.WithValue(ctx, jobIDKey{}, "job_"+id)
+
+	j := &job{
+		id:        "job_" + id,
+		apiKeyID:  apiKeyID,
+		items:     items,
+		accounts:  accounts,
+		status:    models.JobStatusQueued,
+		results:   make([]models.IndexResponse, len(items)),
+		done:      make([]bool, len(items)),
+		progress:  models.JobProgress{Total: len(items)},
+		createdAt: time.Now().UTC(),
+		cancel:    cancel,
+	}
+
+	jm.mutex.Lock()
+	jm.removeExpired(time.Now())
+	jm.jobs[j.id] = j
+	response := j.response(false)
+	jm.mutex.Unlock()
+
+	jm.save(j)
+
+	jm.logger.WithFields(logrus.Fields{
+		"job_id": j.id,
+		"count":  len(items),
+	}).Info("Queued batch job")
+
+	jm.running.Add(1)
+	go jm.run(ctx, j)
+
+	return response, nil
+}
+
+// Get returns a job with the results processed so far. Jobs from before a
+// restart are read from the history store.
+func (jm *JobManager) Get(id string) (*models.JobResponse, error) {
+	jm.mutex.Lock()
+	jm.removeExpired(time.Now())
+	j, exists := jm.jobs[id]
+	if exists {
+		response := j.response(true)
+		jm.mutex.Unlock()
+		return response, nil
+	}
+	jm.mutex.Unlock()
+
+	if jm.history == nil {
+		return nil, ErrJobNotFound
+	}
+
+	stored, err := jm.history.GetJob(context.Background(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// Cancel stops a job. URLs not yet submitted are reported as canceled; calls
+// already in flight finish first. Canceling a finished job has no effect.
+func (jm *JobManager) Cancel(id string) (*models.JobResponse, error) {
+	jm.mutex.Lock()
+
+	j, exists := jm.jobs[id]
+	if !exists {
+		jm.mutex.Unlock()
+		// Jobs from before a restart have already stopped
+		return jm.Get(id)
+	}
+
+	if j.status == models.JobStatusQueued || j.status == models.JobStatusRunning {
+		j.status = models.JobStatusCanceling
+		j.cancel()
+
+		jm.logger.WithField("job_id", id).Info("Canceling batch job")
+	}
+
+	response := j.response(true)
+	jm.mutex.Unlock()
+
+	jm.save(j)
+
+	return response, nil
+}
+
+// Shutdown stops every running job, marking it interrupted, and waits for
+// them to stop, or for ctx to be done.
+func (jm *JobManager) Shutdown(ctx context.Context) error {
+	jm.stopOnce.Do(func() { close(jm.stop) })
+
+	jm.mutex.Lock()
+	for _, j := range jm.jobs {
+		switch j.status {
+		case models.JobStatusQueued, models.JobStatusRunning, models.JobStatusCanceling:
+			j.status = models.JobStatusInterrupted
+			j.cancel()
+		}
+	}
+	jm.mutex.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		jm.running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (jm *JobManager) run(ctx context.Context, j *job) {
+	defer jm.running.Done()
+	defer j.cancel()
+
+	jm.mutex.Lock()
+	if j.status == models.JobStatusQueued {
+		j.status = models.JobStatusRunning
+	}
+	j.startedAt = time.Now().UTC()
+	jm.mutex.Unlock()
+
+	jm.save(j)
+
+	jm.service.SubmitURLsBatchWithProgress(ctx, j.items, j.accounts, func(offset int, results []models.IndexResponse) {
+		defer jm.save(j)
+
+		jm.mutex.Lock()
+		defer jm.mutex.Unlock()
+
+		for i, result := range results {
+			j.results[offset+i] = result
+			j.done[offset+i] = true
+
+			j.progress.Processed++
+			if result.Success {
+				j.progress.Successful++
+			} else {
+				j.progress.Failed++
+			}
+		}
+	})
+
+	jm.mutex.Lock()
+	// Finished jobs stay listed until retention expires; drop what they no
+	// longer need, the accounts may hold a private key
+	j.items = nil
+	j.accounts = nil
+	switch j.status {
+	case models.JobStatusCanceling:
+		j.status = models.JobStatusCanceled
+	case models.JobStatusInterrupted:
+	default:
+		j.status = models.JobStatusCompleted
+	}
+	j.finishedAt = time.Now().UTC()
+	progress := j.progress
+	status := j.status
+	jm.mutex.Unlock()
+
+	jm.save(j)
+
+	jm.logger.WithFields(logrus.Fields{
+		"job_id":   j.id,
+		"status":   status,
+		"progress": progress,
+	}).Info("Batch job finished")
+}
+
+// save writes the current state of j to the history store. Failing to save
+// is logged but does not stop the job.
+func (jm *JobManager) save(j *job) {
+	if jm.history == nil {
+		return
+	}
+
+	j.saveMutex.Lock()
+	defer j.saveMutex.Unlock()
+
+	jm.mutex.Lock()
+	snapshot := j.response(true)
+	jm.mutex.Unlock()
+
+	if err := jm.history.SaveJob(context.Background(), *snapshot); err != nil {
+		jm.logger.WithError(err).WithField("job_id", j.id).Warn("Failed to save batch job")
+	}
+}
+
+// recoverStoredJobs marks jobs left unfinished by a previous run as
+// interrupted, and deletes stored jobs past the retention period.
+func (jm *JobManager) recoverStoredJobs() {
+	if jm.history == nil {
+		return
+	}
+
+	ctx := context.Background()
+	jobs, err := jm.history.ListJobs(ctx)
+	if err != nil {
+		jm.logger.WithError(err).Warn("Failed to load stored batch jobs")
+		return
+	}
+
+	now := time.Now()
+	for _, stored := range jobs {
+		switch stored.Status {
+		case models.JobStatusQueued, models.JobStatusRunning, models.JobStatusCanceling:
+			stored.Status = models.JobStatusInterrupted
+			stored.FinishedAt = now.UTC().Format(time.RFC3339)
+			if err := jm.history.SaveJob(ctx, stored); err != nil {
+				jm.logger.WithError(err).WithField("job_id", stored.ID).Warn("Failed to mark batch job interrupted")
+				continue
+			}
+			jm.logger.WithField("job_id", stored.ID).Warn("Batch job was interrupted by a restart")
+		default:
+			finishedAt, err := time.Parse(time.RFC3339, stored.FinishedAt)
+			if err == nil && now.Sub(finishedAt) > jm.retention {
+				if err := jm.history.DeleteJob(ctx, stored.ID); err != nil {
+					jm.logger.WithError(err).WithField("job_id", stored.ID).Warn("Failed to delete expired batch job")
+				}
+			}
+		}
+	}
+}
+
+// removeExpired forgets finished jobs past the retention period. Callers must
+// hold jm.mutex.
+func (jm *JobManager) removeExpired(now time.Time) {
+	for id, j := range jm.jobs {
+		if !j.finishedAt.IsZero() && now.Sub(j.finishedAt) > jm.retention {
+			delete(jm.jobs, id)
+			if jm.history != nil {
+				if err := jm.history.DeleteJob(context.Background(), id); err != nil {
+					jm.logger.WithError(err).WithField("job_id", id).Warn("Failed to delete expired batch job")
+				}
+			}
+		}
+	}
+}
+
+// response describes the job. Callers must hold the manager's mutex.
+func (j *job) response(withResults bool) *models.JobResponse {
+	response := &models.JobResponse{
+		ID:        j.id,
+		Status:    j.status,
+		APIKeyID:  j.apiKeyID,
+		Progress:  j.progress,
+		CreatedAt: j.createdAt.Format(time.RFC3339),
+	}
+
+	if !j.startedAt.IsZero() {
+		response.StartedAt = j.startedAt.Format(time.RFC3339)
+	}
+	if !j.finishedAt.IsZero() {
+		response.FinishedAt = j.finishedAt.Format(time.RFC3339)
+	}
+
+	if withResults {
+		response.Results = make([]models.IndexResponse, 0, j.progress.Processed)
+		for i, result := range j.results {
+			if j.done[i] {
+				response.Results = append(response.Results, result)
+			}
+		}
+	}
+
+	return response
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/internal/store"
+)
+
+// failingStore is a history store whose job reads fail.
+type failingStore struct {
+	store.Store
+}
+
+func (s *failingStore) SaveJob(ctx context.Context, job models.JobResponse) error { return nil }
+func (s *failingStore) DeleteJob(ctx context.Context, id string) error            { return nil }
+
+func (s *failingStore) ListJobs(ctx context.Context) ([]models.JobResponse, error) {
+	return nil, nil
+}
+
+func (s *failingStore) GetJob(ctx context.Context, id string) (*models.JobResponse, error) {
+	return nil, errors.New("disk I/O error")
+}
+
+func TestJobManagerEvictsExpiredJobs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/batch" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"urlNotificationMetadata":{"url":"x"}}`)
+	}))
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	credentials := &testCredentials{email: "only@p1", project: "p1"}
+	addTestClient(t, gis, server, credentials)
+
+	jm := NewJobManager(gis, nil, 10*time.Millisecond, gis.logger)
+	defer jm.Shutdown(context.Background())
+
+	job, err := jm.Submit(testItems(2), SingleAccount(credentials), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jm.running.Wait()
+
+	if finished, err := jm.Get(job.ID); err != nil || finished.Status != models.JobStatusCompleted {
+		t.Fatalf("got %v, want the completed job", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := jm.Get(job.ID); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("got %v for an expired job, want %v", err, ErrJobNotFound)
+	}
+	jm.mutex.Lock()
+	kept := len(jm.jobs)
+	jm.mutex.Unlock()
+	if kept != 0 {
+		t.Errorf("%d expired jobs are still kept", kept)
+	}
+}
+
+func TestJobManagerGetReportsStoreErrors(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	jm := NewJobManager(nil, &failingStore{}, time.Hour, logger)
+	defer jm.Shutdown(context.Background())
+
+	_, err := jm.Get("job_missing")
+	if err == nil || errors.Is(err, ErrJobNotFound) {
+		t.Errorf("got %v, want the store error", err)
+	}
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// masterKeySize is the size of master and data keys (AES-256).
+const masterKeySize = 32
+
+// KeyringConfig says where master keys come from. Keys given directly take
+// precedence over the key file.
+type KeyringConfig struct {
+	// MasterKey is the base64 encoded primary master key.
+	MasterKey string
+	// PreviousMasterKeys are older base64 encoded keys, kept for decryption
+	// until every secret has been re-wrapped with the primary key.
+	PreviousMasterKeys []string
+	// MasterKeyFile holds base64 encoded keys, one per line, primary first.
+	// It is created with a random key when it does not exist and no key was
+	// given directly.
+	MasterKeyFile string
+}
+
+// Keyring holds the master keys used for envelope encryption. Every secret is
+// encrypted with its own random data key, and only the data key is encrypted
+// ("wrapped") with a master key. Rotating the master key therefore only needs
+// to re-wrap data keys, never to re-encrypt the secrets themselves.
+type Keyring struct {
+	mutex   sync.RWMutex
+	config  KeyringConfig
+	primary string
+	keys    map[string][]byte
+}
+
+// encryptedSecret is a secret sealed with envelope encryption. Both the data
+// key and the ciphertext carry their AES-GCM nonce as a prefix.
+type encryptedSecret struct {
+	KeyID      string `json:"key_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// LoadKeyring loads the master keys described by config.
+func LoadKeyring(config KeyringConfig) (*Keyring, error) {
+	keyring := &Keyring{config: config}
+	if err := keyring.Reload(); err != nil {
+		return nil, err
+	}
+
+	return keyring, nil
+}
+
+// Reload reads the master keys again, e.g. after a new primary key was added
+// to the key file. The new keys only replace the loaded ones once they can
+// unwrap every secret in secrets, so a key that is still in use cannot be
+// dropped by mistake.
+func (k *Keyring) Reload(secrets ...*encryptedSecret) error {
+	encoded, err := readMasterKeys(k.config)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string][]byte, len(encoded))
+	primary := ""
+
+	for i, value := range encoded {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil || len(key) != masterKeySize {
+			return fmt.Errorf("master key %d must be %d base64 encoded bytes", i+1, masterKeySize)
+		}
+
+		id := masterKeyID(key)
+		keys[id] = key
+		if i == 0 {
+			primary = id
+		}
+	}
+
+	for _, secret := range secrets {
+		if _, err := unwrapWith(keys, secret); err != nil {
+			return fmt.Errorf("new master keys cannot decrypt existing secrets, keep the previous key configured until they are re-wrapped: %v", err)
+		}
+	}
+
+	k.mutex.Lock()
+	k.keys = keys
+	k.primary = primary
+	k.mutex.Unlock()
+
+	return nil
+}
+
+// PrimaryKeyID returns the ID of the key new secrets are wrapped with.
+func (k *Keyring) PrimaryKeyID() string {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return k.primary
+}
+
+// seal encrypts plaintext with a fresh data key wrapped by the primary master
+// key. aad is authenticated but not encrypted, binding the secret to its owner.
+func (k *Keyring) seal(plaintext []byte, aad []byte) (*encryptedSecret, error) {
+	dataKey := make([]byte, masterKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := gcmSeal(dataKey, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mutex.RLock()
+	keyID, masterKey := k.primary, k.keys[k.primary]
+	k.mutex.RUnlock()
+
+	wrappedKey, err := gcmSeal(masterKey, dataKey, []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedSecret{
+		KeyID:      keyID,
+		WrappedKey: wrappedKey,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// open decrypts a secret sealed with seal.
+func (k *Keyring) open(secret *encryptedSecret, aad []byte) ([]byte, error) {
+	dataKey, err := k.unwrap(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return gcmOpen(dataKey, secret.Ciphertext, aad)
+}
+
+// rewrap wraps the data key of secret with the primary master key. It returns
+// false when the secret already uses the primary key.
+func (k *Keyring) rewrap(secret *encryptedSecret) (*encryptedSecret, bool, error) {
+	k.mutex.RLock()
+	keyID, masterKey := k.primary, k.keys[k.primary]
+	k.mutex.RUnlock()
+
+	if secret.KeyID == keyID {
+		return secret, false, nil
+	}
+
+	dataKey, err := k.unwrap(secret)
+	if err != nil {
+		return nil, false, err
+	}
+
+	wrappedKey, err := gcmSeal(masterKey, dataKey, []byte(keyID))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &encryptedSecret{
+		KeyID:      keyID,
+		WrappedKey: wrappedKey,
+		Ciphertext: secret.Ciphertext,
+	}, true, nil
+}
+
+func (k *Keyring) unwrap(secret *encryptedSecret) ([]byte, error) {
+	k.mutex.RLock()
+	keys := k.keys
+	k.mutex.RUnlock()
+
+	return unwrapWith(keys, secret)
+}
+
+// unwrapWith decrypts the data key of secret with the matching key of keys.
+func unwrapWith(keys map[string][]byte, secret *encryptedSecret) ([]byte, error) {
+	masterKey, exists := keys[secret.KeyID]
+	if !exists {
+		return nil, fmt.Errorf("master key %s is not loaded", secret.KeyID)
+	}
+
+	dataKey, err := gcmOpen(masterKey, secret.WrappedKey, []byte(secret.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %v", err)
+	}
+
+	return dataKey, nil
+}
+
+// readMasterKeys returns the encoded master keys, primary first.
+func readMasterKeys(config KeyringConfig) ([]string, error) {
+	if config.MasterKey != "" {
+		keys := []string{config.MasterKey}
+		for _, key := range config.PreviousMasterKeys {
+			if strings.TrimSpace(key) != "" {
+				keys = append(keys, key)
+			}
+		}
+		return keys, nil
+	}
+
+	if config.MasterKeyFile == "" {
+		return nil, errors.New("no master key configured")
+	}
+
+	file, err := os.Open(config.MasterKeyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return generateMasterKeyFile(config.MasterKeyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open master key file: %v", err)
+	}
+	defer file.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %v", err)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("master key file %s contains no keys", config.MasterKeyFile)
+	}
+
+	return keys, nil
+}
+
+func generateMasterKeyFile(path string) ([]string, error) {
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(key)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create master key directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(encoded+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write master key file: %v", err)
+	}
+
+	return []string{encoded}, nil
+}
+
+// masterKeyID derives a stable, non-secret ID from a master key.
+func masterKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func gcmSeal(key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func gcmOpen(key, sealed, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
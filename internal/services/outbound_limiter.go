@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google-indexing-api/pkg/utils"
+)
+
+// outboundLimiter paces publish requests to Google per project, so bursts from
+// concurrent batches and jobs wait for their turn instead of being rejected
+// with 429. A batch call counts as one request per notification it carries,
+// as it does for Google.
+type outboundLimiter struct {
+	perMinute int
+	perSecond int
+	buckets   map[string]*projectBuckets
+	mutex     sync.Mutex
+}
+
+// projectBuckets are the buckets of one project. A nil bucket is unlimited.
+type projectBuckets struct {
+	minute *utils.TokenBucket
+	second *utils.TokenBucket
+}
+
+// newOutboundLimiter creates a limiter allowing perMinute and perSecond
+// requests per project. A limit of 0 or less is disabled.
+func newOutboundLimiter(perMinute int, perSecond int) *outboundLimiter {
+	return &outboundLimiter{
+		perMinute: perMinute,
+		perSecond: perSecond,
+		buckets:   make(map[string]*projectBuckets),
+	}
+}
+
+// wait blocks until n requests for projectID may be sent, or ctx is done, and
+// returns how long it waited.
+func (l *outboundLimiter) wait(ctx context.Context, projectID string, n int) (time.Duration, error) {
+	if l.perMinute <= 0 && l.perSecond <= 0 {
+		return 0, nil
+	}
+
+	buckets := l.bucketsFor(projectID)
+	start := time.Now()
+
+	for i := 0; i < n; i++ {
+		// The scarcer minute token is held while waiting for a second token
+		for _, bucket := range []*utils.TokenBucket{buckets.minute, buckets.second} {
+			if bucket == nil {
+				continue
+			}
+			if err := takeToken(ctx, bucket); err != nil {
+				return time.Since(start), err
+			}
+		}
+	}
+
+	return time.Since(start), nil
+}
+
+func (l *outboundLimiter) bucketsFor(projectID string) *projectBuckets {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	buckets, exists := l.buckets[projectID]
+	if !exists {
+		buckets = &projectBuckets{}
+		if l.perMinute > 0 {
+			buckets.minute = utils.NewTokenBucket(l.perMinute, float64(l.perMinute)/60)
+		}
+		if l.perSecond > 0 {
+			buckets.second = utils.NewTokenBucket(l.perSecond, float64(l.perSecond))
+		}
+		l.buckets[projectID] = buckets
+	}
+
+	return buckets
+}
+
+// takeToken waits until a token can be taken from bucket, or ctx is done.
+func takeToken(ctx context.Context, bucket *utils.TokenBucket) error {
+	for {
+		ok, _, retryIn := bucket.Take()
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(max(retryIn, time.Millisecond))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatchFallbackIsNotChargedTwice(t *testing.T) {
+	var publishes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/batch" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		publishes.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"urlNotificationMetadata":{"url":"x"}}`)
+	}))
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	// Enough for each notification once; charging twice would wait ~12s
+	gis.outbound = newOutboundLimiter(100, 0)
+
+	credentials := &testCredentials{email: "only@p1", project: "p1"}
+	addTestClient(t, gis, server, credentials)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	response, err := gis.SubmitURLsBatch(ctx, testItems(60), SingleAccount(credentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Statistics.Successful != 60 {
+		t.Errorf("got %d successful URLs, want 60", response.Statistics.Successful)
+	}
+	if publishes.Load() != 60 {
+		t.Errorf("got %d individual publishes, want 60", publishes.Load())
+	}
+
+	_, remaining, _ := gis.outbound.bucketsFor("p1").minute.Take()
+	if remaining != 39 {
+		t.Errorf("got %d outbound tokens left, want 39", remaining)
+	}
+}
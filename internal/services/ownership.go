@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/searchconsole/v1"
+)
+
+// domainPropertyPrefix marks Search Console domain properties, which cover a
+// host and all its subdomains over any scheme.
+const domainPropertyPrefix = "sc-domain:"
+
+// ownershipFailureTTL is how long a failed property lookup is remembered, so
+// an unreachable Search Console API is not called again for every URL.
+const ownershipFailureTTL = time.Minute
+
+// ownershipChecker caches the Search Console properties each service account
+// is a verified owner of. Google only accepts notifications for URLs covered
+// by such a property, so URLs outside them can be rejected before they cost a
+// publish call.
+type ownershipChecker struct {
+	endpoint   string
+	ttl        time.Duration
+	properties map[string]*ownedProperties
+	mutex      sync.Mutex
+}
+
+// ownedProperties are the verified properties of one account, or the error
+// listing them. ready is closed once the lookup is done; until then other
+// callers wait for it instead of starting their own.
+type ownedProperties struct {
+	ready     chan struct{}
+	sites     []string
+	err       error
+	expiresAt time.Time
+}
+
+// newOwnershipChecker creates a checker caching properties for ttl. An empty
+// endpoint uses Google's Search Console API.
+func newOwnershipChecker(endpoint string, ttl time.Duration) *ownershipChecker {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+
+	return &ownershipChecker{
+		endpoint:   endpoint,
+		ttl:        ttl,
+		properties: make(map[string]*ownedProperties),
+	}
+}
+
+// lookup returns the entry of clientEmail. fetch is true when the caller
+// must do the lookup and call done, because no entry was cached or it
+// expired.
+func (oc *ownershipChecker) lookup(clientEmail string) (owned *ownedProperties, fetch bool) {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+
+	if owned, exists := oc.properties[clientEmail]; exists {
+		select {
+		case <-owned.ready:
+			if time.Now().Before(owned.expiresAt) {
+				return owned, false
+			}
+		default:
+			// Still being looked up
+			return owned, false
+		}
+	}
+
+	owned = &ownedProperties{ready: make(chan struct{})}
+	oc.properties[clientEmail] = owned
+
+	return owned, true
+}
+
+// done stores the outcome of a lookup and releases its waiters. Failures are
+// kept for ownershipFailureTTL only.
+func (oc *ownershipChecker) done(owned *ownedProperties, sites []string, err error) {
+	ttl := oc.ttl
+	if err != nil {
+		ttl = ownershipFailureTTL
+	}
+
+	oc.mutex.Lock()
+	owned.sites = sites
+	owned.err = err
+	owned.expiresAt = time.Now().Add(ttl)
+	oc.mutex.Unlock()
+
+	close(owned.ready)
+}
+
+// verifiedProperties returns the Search Console properties client is a
+// verified owner of, listing them through the Search Console API on a cache
+// miss.
+func (gis *GoogleIndexingService) verifiedProperties(ctx context.Context, client *indexingClient) ([]string, error) {
+	owned, fetch := gis.ownership.lookup(client.clientEmail)
+	if !fetch {
+		select {
+		case <-owned.ready:
+			return owned.sites, owned.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	var resp *searchconsole.SitesListResponse
+	// The lookup is shared, so it must not fail with the first caller
+	err := gis.call(context.WithoutCancel(ctx), "sitesList", func(callCtx context.Context) error {
+		var callErr error
+		resp, callErr = client.sites.Sites.List().Context(callCtx).Do()
+		return callErr
+	})
+	if err != nil {
+		gis.logger.WithError(err).WithField("service_account", client.clientEmail).Warn("Failed to list Search Console properties, skipping ownership check")
+		gis.ownership.done(owned, nil, err)
+		return nil, err
+	}
+
+	var sites []string
+	for _, entry := range resp.SiteEntry {
+		// Full and restricted users cannot use the Indexing API
+		if entry.PermissionLevel == "siteOwner" {
+			sites = append(sites, entry.SiteUrl)
+		}
+	}
+
+	gis.ownership.done(owned, sites, nil)
+
+	gis.logger.WithField("service_account", client.clientEmail).WithField("properties", len(sites)).Info("Loaded verified Search Console properties")
+
+	return sites, nil
+}
+
+// ownsURL reports whether credentials are a verified owner of a Search Console
+// property covering rawURL. It is always true with the check disabled. When
+// the properties cannot be listed, the URL is let through and Google decides.
+func (gis *GoogleIndexingService) ownsURL(ctx context.Context, credentials Credentials, rawURL string) bool {
+	if gis.ownership == nil {
+		return true
+	}
+
+	client, err := gis.getIndexingService(ctx, credentials)
+	if err != nil {
+		return true
+	}
+
+	sites, err := gis.verifiedProperties(ctx, client)
+	if err != nil {
+		return true
+	}
+
+	for _, site := range sites {
+		if propertyCovers(site, rawURL) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// propertyCovers reports whether a Search Console property covers rawURL. A
+// domain property (sc-domain:example.com) covers the host and its subdomains,
+// a URL-prefix property (https://example.com/blog/) the URLs starting with it.
+func propertyCovers(property string, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	if domain, ok := strings.CutPrefix(property, domainPropertyPrefix); ok {
+		host := strings.ToLower(u.Hostname())
+		domain = strings.ToLower(domain)
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	}
+
+	prefix, err := url.Parse(property)
+	if err != nil || prefix.Host == "" {
+		return false
+	}
+
+	return strings.HasPrefix(urlPrefix(u), urlPrefix(prefix))
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/searchconsole/v1"
+
+	"google-indexing-api/internal/models"
+)
+
+// newOwnershipServer fakes the Search Console sites list and the publish
+// endpoint. sites maps an account to its sites list response, and an account
+// without one gets a 403.
+func newOwnershipServer(sites map[string]string, lists *atomic.Int32, publishes *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.URL.Path == "/webmasters/v3/sites":
+			lists.Add(1)
+			body, exists := sites[r.Header.Get(whoHeader)]
+			if !exists {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"error":{"code":403,"message":"Insufficient scope"}}`)
+				return
+			}
+			fmt.Fprint(w, body)
+		case strings.HasSuffix(r.URL.Path, "urlNotifications:publish"):
+			publishes.Add(1)
+			fmt.Fprint(w, `{"urlNotificationMetadata":{"url":"x"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func addOwnershipClient(t *testing.T, gis *GoogleIndexingService, server *httptest.Server, credentials *testCredentials) {
+	t.Helper()
+
+	client := addTestClient(t, gis, server, credentials)
+
+	sites, err := searchconsole.NewService(context.Background(), option.WithEndpoint(gis.ownership.endpoint), option.WithHTTPClient(client.httpClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.sites = sites
+}
+
+func TestOwnershipCheck(t *testing.T) {
+	var lists, publishes atomic.Int32
+	server := newOwnershipServer(map[string]string{
+		"owner@p1": `{"siteEntry":[
+			{"siteUrl":"sc-domain:Example.com","permissionLevel":"siteOwner"},
+			{"siteUrl":"https://shop.test/en/","permissionLevel":"siteOwner"},
+			{"siteUrl":"sc-domain:full.test","permissionLevel":"siteFullUser"},
+			{"siteUrl":"sc-domain:unverified.test","permissionLevel":"siteUnverifiedUser"}
+		]}`,
+	}, &lists, &publishes)
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	gis.ownership = newOwnershipChecker(server.URL+"/", time.Hour)
+
+	credentials := &testCredentials{email: "owner@p1", project: "p1"}
+	addOwnershipClient(t, gis, server, credentials)
+
+	tests := []struct {
+		url   string
+		owned bool
+	}{
+		{"https://example.com/page", true},
+		{"http://www.example.com/page", true},
+		{"https://notexample.com/page", false},
+		{"https://SHOP.test/en/product", true},
+		{"https://shop.test/de/product", false},
+		{"http://shop.test/en/product", false},
+		{"https://full.test/page", false},
+		{"https://unverified.test/page", false},
+	}
+
+	for _, tt := range tests {
+		response, err := gis.SubmitURL(context.Background(), tt.url, "", SingleAccount(credentials))
+
+		if tt.owned && (err != nil || !response.Success) {
+			t.Errorf("%s: got %q (%v), want success", tt.url, response.ErrorKind, err)
+		}
+		if !tt.owned && response.ErrorKind != models.ErrorKindNotOwned {
+			t.Errorf("%s: got error kind %q, want %q", tt.url, response.ErrorKind, models.ErrorKindNotOwned)
+		}
+		if !tt.owned && !strings.Contains(response.Message, "Search Console property") {
+			t.Errorf("%s: message %q does not explain the rejection", tt.url, response.Message)
+		}
+	}
+
+	if lists.Load() != 1 {
+		t.Errorf("got %d sites list calls, want 1", lists.Load())
+	}
+	if want := int32(3); publishes.Load() != want {
+		t.Errorf("got %d publish calls, want %d", publishes.Load(), want)
+	}
+}
+
+func TestOwnershipCheckPool(t *testing.T) {
+	var lists, publishes atomic.Int32
+	server := newOwnershipServer(map[string]string{
+		"a@p1": `{"siteEntry":[{"siteUrl":"sc-domain:a.test","permissionLevel":"siteOwner"}]}`,
+		"b@p1": `{"siteEntry":[{"siteUrl":"https://b.test/","permissionLevel":"siteOwner"}]}`,
+	}, &lists, &publishes)
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	gis.ownership = newOwnershipChecker(server.URL+"/", time.Hour)
+
+	a := &testCredentials{email: "a@p1", project: "p1"}
+	b := &testCredentials{email: "b@p1", project: "p1"}
+	addOwnershipClient(t, gis, server, a)
+	addOwnershipClient(t, gis, server, b)
+
+	accounts := &Accounts{
+		pool:     "test",
+		strategy: models.PoolStrategyRoundRobin,
+		members:  []Credentials{a, b},
+		cursor:   new(atomic.Uint64),
+	}
+
+	items := []models.BatchIndexItem{
+		{URL: "https://www.a.test/1", Type: models.NotificationTypeUpdated},
+		{URL: "https://b.test/1", Type: models.NotificationTypeUpdated},
+		{URL: "https://c.test/1", Type: models.NotificationTypeUpdated},
+		{URL: "https://a.test/2", Type: models.NotificationTypeUpdated},
+	}
+	want := []string{"a@p1", "b@p1", "", "a@p1"}
+
+	response, err := gis.SubmitURLsBatch(context.Background(), items, accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, result := range response.Results {
+		if result.ServiceAccount != want[i] {
+			t.Errorf("%s was sent with %q, want %q", result.URL, result.ServiceAccount, want[i])
+		}
+	}
+	if kind := response.Results[2].ErrorKind; kind != models.ErrorKindNotOwned {
+		t.Errorf("got error kind %q for an unowned URL, want %q", kind, models.ErrorKindNotOwned)
+	}
+	if lists.Load() != 2 {
+		t.Errorf("got %d sites list calls, want one per member", lists.Load())
+	}
+}
+
+func TestOwnershipCheckCachesFailures(t *testing.T) {
+	var lists, publishes atomic.Int32
+	server := newOwnershipServer(map[string]string{}, &lists, &publishes)
+	defer server.Close()
+
+	gis := newTestService(t, server)
+	gis.ownership = newOwnershipChecker(server.URL+"/", time.Hour)
+
+	credentials := &testCredentials{email: "denied@p1", project: "p1"}
+	addOwnershipClient(t, gis, server, credentials)
+
+	// Without a property list, URLs are let through and Google decides
+	response, err := gis.SubmitURLsBatch(context.Background(), testItems(100), SingleAccount(credentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Statistics.Successful != 100 {
+		t.Errorf("got %d successful URLs, want 100", response.Statistics.Successful)
+	}
+	if lists.Load() != 1 {
+		t.Errorf("got %d sites list calls, want 1", lists.Load())
+	}
+}
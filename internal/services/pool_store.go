@@ -0,0 +1,311 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/pkg/utils"
+)
+
+var (
+	// ErrPoolNotFound is returned for unknown pool names.
+	ErrPoolNotFound = errors.New("pool not found")
+	// ErrPoolExists is returned when creating a pool under a name in use.
+	ErrPoolExists = errors.New("pool already exists")
+)
+
+// poolStoreVersion is the on-disk format version of the store file.
+const poolStoreVersion = 1
+
+// poolNamePattern keeps pool names usable in URLs.
+var poolNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// PoolStore keeps named pools of registered credentials. A submission naming a
+// pool spreads its URLs over the members, see Accounts.
+type PoolStore struct {
+	logger      *logrus.Logger
+	credentials *CredentialStore
+	path        string
+	pools       map[string]*storedPool
+	mutex       sync.RWMutex
+}
+
+type storedPool struct {
+	Name          string    `json:"name"`
+	CredentialIDs []string  `json:"credential_ids"`
+	Strategy      string    `json:"strategy"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	// cursor is the round robin position, shared by every request using the
+	// pool. It is not persisted.
+	cursor *atomic.Uint64
+}
+
+type poolStoreFile struct {
+	Version int           `json:"version"`
+	Pools   []*storedPool `json:"pools"`
+}
+
+// NewPoolStore loads the pools persisted at path. An empty path keeps pools in
+// memory only. Members are resolved against credentials.
+func NewPoolStore(path string, credentials *CredentialStore, logger *logrus.Logger) (*PoolStore, error) {
+	ps := &PoolStore{
+		logger:      logger,
+		credentials: credentials,
+		path:        path,
+		pools:       make(map[string]*storedPool),
+	}
+
+	if path == "" {
+		return ps, nil
+	}
+
+	var file poolStoreFile
+	if _, err := utils.ReadJSONFile(path, &file); err != nil {
+		return nil, fmt.Errorf("failed to load pool store: %v", err)
+	}
+
+	for _, pool := range file.Pools {
+		pool.cursor = new(atomic.Uint64)
+		ps.pools[pool.Name] = pool
+	}
+
+	return ps, nil
+}
+
+// Create stores a new pool. Every member must be a registered credential.
+func (ps *PoolStore) Create(name string, credentialIDs []string, strategy string) (*models.PoolInfo, error) {
+	if !poolNamePattern.MatchString(name) {
+		return nil, errors.New("pool name must be 1 to 64 letters, digits, '.', '_' or '-'")
+	}
+	if err := ps.validateMembers(credentialIDs); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	pool := &storedPool{
+		Name:          name,
+		CredentialIDs: uniqueStrings(credentialIDs),
+		Strategy:      poolStrategy(strategy),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		cursor:        new(atomic.Uint64),
+	}
+
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	if _, exists := ps.pools[name]; exists {
+		return nil, ErrPoolExists
+	}
+
+	ps.pools[name] = pool
+	if err := ps.save(); err != nil {
+		delete(ps.pools, name)
+		return nil, err
+	}
+
+	ps.logger.WithFields(logrus.Fields{
+		"pool":     name,
+		"members":  len(pool.CredentialIDs),
+		"strategy": pool.Strategy,
+	}).Info("Created credential pool")
+
+	info := pool.info()
+	return &info, nil
+}
+
+// Get returns a pool.
+func (ps *PoolStore) Get(name string) (*models.PoolInfo, error) {
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+
+	pool, exists := ps.pools[name]
+	if !exists {
+		return nil, ErrPoolNotFound
+	}
+
+	info := pool.info()
+	return &info, nil
+}
+
+// List returns every pool, sorted by name.
+func (ps *PoolStore) List() []models.PoolInfo {
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+
+	list := make([]models.PoolInfo, 0, len(ps.pools))
+	for _, pool := range ps.pools {
+		list = append(list, pool.info())
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+// Update replaces the members and strategy of a pool.
+func (ps *PoolStore) Update(name string, credentialIDs []string, strategy string) (*models.PoolInfo, error) {
+	if err := ps.validateMembers(credentialIDs); err != nil {
+		return nil, err
+	}
+
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	pool, exists := ps.pools[name]
+	if !exists {
+		return nil, ErrPoolNotFound
+	}
+
+	updated := *pool
+	updated.CredentialIDs = uniqueStrings(credentialIDs)
+	updated.Strategy = poolStrategy(strategy)
+	updated.UpdatedAt = time.Now().UTC()
+
+	ps.pools[name] = &updated
+	if err := ps.save(); err != nil {
+		ps.pools[name] = pool
+		return nil, err
+	}
+
+	ps.logger.WithFields(logrus.Fields{
+		"pool":     name,
+		"members":  len(updated.CredentialIDs),
+		"strategy": updated.Strategy,
+	}).Info("Updated credential pool")
+
+	info := updated.info()
+	return &info, nil
+}
+
+// Delete removes a pool. Its credentials stay registered.
+func (ps *PoolStore) Delete(name string) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	pool, exists := ps.pools[name]
+	if !exists {
+		return ErrPoolNotFound
+	}
+
+	delete(ps.pools, name)
+	if err := ps.save(); err != nil {
+		ps.pools[name] = pool
+		return err
+	}
+
+	ps.logger.WithField("pool", name).Info("Deleted credential pool")
+
+	return nil
+}
+
+// Accounts returns the members of a pool for use with the indexing service.
+// Members whose credential has since been deleted are skipped.
+func (ps *PoolStore) Accounts(name string) (*Accounts, error) {
+	ps.mutex.RLock()
+	pool, exists := ps.pools[name]
+	ps.mutex.RUnlock()
+
+	if !exists {
+		return nil, ErrPoolNotFound
+	}
+
+	accounts := &Accounts{
+		pool:     pool.Name,
+		strategy: pool.Strategy,
+		cursor:   pool.cursor,
+	}
+
+	for _, id := range pool.CredentialIDs {
+		credentials, err := ps.credentials.Credentials(id)
+		if err != nil {
+			ps.logger.WithFields(logrus.Fields{
+				"pool":          name,
+				"credential_id": id,
+			}).Warn("Skipping pool member that is no longer registered")
+			continue
+		}
+		accounts.members = append(accounts.members, credentials)
+	}
+
+	if len(accounts.members) == 0 {
+		return nil, fmt.Errorf("pool %s has no registered credentials left", name)
+	}
+
+	return accounts, nil
+}
+
+func (ps *PoolStore) validateMembers(credentialIDs []string) error {
+	for _, id := range credentialIDs {
+		if _, err := ps.credentials.Get(id); err != nil {
+			return fmt.Errorf("unknown credential_id %q", id)
+		}
+	}
+
+	return nil
+}
+
+// save persists the store. Callers must hold ps.mutex.
+func (ps *PoolStore) save() error {
+	if ps.path == "" {
+		return nil
+	}
+
+	file := poolStoreFile{
+		Version: poolStoreVersion,
+		Pools:   make([]*storedPool, 0, len(ps.pools)),
+	}
+	for _, pool := range ps.pools {
+		file.Pools = append(file.Pools, pool)
+	}
+
+	if err := utils.WriteJSONFile(ps.path, &file, 0o600); err != nil {
+		return fmt.Errorf("failed to save pool store: %v", err)
+	}
+
+	return nil
+}
+
+func (p *storedPool) info() models.PoolInfo {
+	return models.PoolInfo{
+		Name:          p.Name,
+		CredentialIDs: p.CredentialIDs,
+		Strategy:      p.Strategy,
+		CreatedAt:     p.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     p.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// poolStrategy defaults an empty strategy to round robin.
+func poolStrategy(strategy string) string {
+	if strategy == "" {
+		return models.PoolStrategyRoundRobin
+	}
+	return strategy
+}
+
+// uniqueStrings drops duplicates, keeping the first occurrence of each value.
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+}
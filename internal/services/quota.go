@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	// Google's quota day follows Pacific time, which must resolve even on
+	// hosts without a zoneinfo database
+	_ "time/tzdata"
+
+	"google.golang.org/api/googleapi"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/internal/store"
+)
+
+// quotaLocation is the time zone Google resets daily quotas in.
+const quotaLocation = "America/Los_Angeles"
+
+// QuotaExceededError is returned when a request needs more publish calls than
+// its project, or the projects of its pool, have left for the day.
+type QuotaExceededError struct {
+	ProjectID string
+	Pool      string
+	Requested int
+	Remaining int
+	ResetAt   time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	scope := "project " + e.ProjectID
+	if e.Pool != "" {
+		scope = "pool " + e.Pool
+	}
+
+	return fmt.Sprintf("daily publish quota of %s exceeded: %d URLs requested, %d remaining until %s",
+		scope, e.Requested, e.Remaining, e.ResetAt.UTC().Format(time.RFC3339))
+}
+
+// quotaTracker counts publish calls sent to Google per project and service
+// account for the current quota day. Counts are kept in memory and re-seeded
+// from the submission history on startup.
+type quotaTracker struct {
+	defaultLimit  int
+	projectLimits map[string]int
+	location      *time.Location
+	day           time.Time
+	usage         map[string]map[string]int
+	mutex         sync.Mutex
+}
+
+func newQuotaTracker(defaultLimit int, projectLimits map[string]int) (*quotaTracker, error) {
+	location, err := time.LoadLocation(quotaLocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quota time zone: %v", err)
+	}
+
+	return &quotaTracker{
+		defaultLimit:  defaultLimit,
+		projectLimits: projectLimits,
+		location:      location,
+		usage:         make(map[string]map[string]int),
+	}, nil
+}
+
+// limit returns the daily limit of a project, 0 meaning unlimited.
+func (qt *quotaTracker) limit(projectID string) int {
+	if limit, exists := qt.projectLimits[projectID]; exists {
+		return limit
+	}
+	return qt.defaultLimit
+}
+
+// rollover forgets the counts of a previous quota day. Callers must hold
+// qt.mutex.
+func (qt *quotaTracker) rollover(now time.Time) {
+	local := now.In(qt.location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, qt.location)
+	if !day.Equal(qt.day) {
+		qt.day = day
+		qt.usage = make(map[string]map[string]int)
+	}
+}
+
+// resetAt returns when the current quota day ends. Callers must hold
+// qt.mutex.
+func (qt *quotaTracker) resetAt() time.Time {
+	return qt.day.AddDate(0, 0, 1)
+}
+
+func (qt *quotaTracker) add(projectID string, clientEmail string, count int) {
+	qt.mutex.Lock()
+	defer qt.mutex.Unlock()
+
+	qt.rollover(time.Now())
+
+	accounts, exists := qt.usage[projectID]
+	if !exists {
+		accounts = make(map[string]int)
+		qt.usage[projectID] = accounts
+	}
+	accounts[clientEmail] += count
+}
+
+// record counts a publish call that reached Google. Calls that failed before
+// Google answered, or that Google rejected for lack of quota, do not use
+// quota.
+func (qt *quotaTracker) record(client *indexingClient, err error) {
+	var apiErr *googleapi.Error
+	if err != nil && (!errors.As(err, &apiErr) || isQuotaExhausted(err)) {
+		return
+	}
+
+	qt.add(client.projectID, client.clientEmail, 1)
+}
+
+// check returns a QuotaExceededError if count more publish calls would go
+// over the combined daily limit of the projects of accounts.
+func (qt *quotaTracker) check(accounts *Accounts, count int) error {
+	total := 0
+	projects := make(map[string]bool)
+	for _, member := range accounts.members {
+		projectID := member.ProjectID()
+		if projects[projectID] {
+			continue
+		}
+		projects[projectID] = true
+
+		remaining := qt.remaining(projectID)
+		if remaining < 0 {
+			return nil
+		}
+		total += remaining
+	}
+
+	if count <= total {
+		return nil
+	}
+
+	qt.mutex.Lock()
+	resetAt := qt.resetAt()
+	qt.mutex.Unlock()
+
+	err := &QuotaExceededError{
+		Pool:      accounts.pool,
+		Requested: count,
+		Remaining: total,
+		ResetAt:   resetAt,
+	}
+	if accounts.pool == "" {
+		err.ProjectID = accounts.members[0].ProjectID()
+	}
+
+	return err
+}
+
+// remaining returns the publish calls projectID has left today, or -1 when
+// it has no limit.
+func (qt *quotaTracker) remaining(projectID string) int {
+	limit := qt.limit(projectID)
+	if limit <= 0 {
+		return -1
+	}
+
+	qt.mutex.Lock()
+	defer qt.mutex.Unlock()
+
+	qt.rollover(time.Now())
+
+	used := 0
+	for _, accountUsed := range qt.usage[projectID] {
+		used += accountUsed
+	}
+
+	return max(limit-used, 0)
+}
+
+// accountUsed returns the publish calls made today with clientEmail in
+// projectID.
+func (qt *quotaTracker) accountUsed(projectID string, clientEmail string) int {
+	qt.mutex.Lock()
+	defer qt.mutex.Unlock()
+
+	qt.rollover(time.Now())
+
+	return qt.usage[projectID][clientEmail]
+}
+
+// seed counts the publish calls of the current quota day already recorded in
+// history, so a restart does not reset usage. The history is walked newest
+// first and the walk stops at the start of the day, so startup only reads the
+// records of the day however long the history is.
+func (qt *quotaTracker) seed(ctx context.Context, history store.Store) error {
+	qt.mutex.Lock()
+	qt.rollover(time.Now())
+	query := store.NotificationQuery{Since: qt.day, Until: qt.resetAt(), Limit: 1000}
+	qt.mutex.Unlock()
+
+	for {
+		records, more, err := history.ListNotifications(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			// Attempts include retries. Records of calls that never reached
+			// Google are skipped, as in record
+			if record.Success || record.ErrorKind == models.ErrorKindGoogleAPI {
+				qt.add(record.ProjectID, record.ServiceAccount, record.Attempts)
+			}
+		}
+
+		if !more {
+			return nil
+		}
+		query.Before = records[len(records)-1].ID
+	}
+}
+
+// usageReport describes the usage of the current quota day. Projects with a
+// configured limit are listed even before their first call.
+func (qt *quotaTracker) usageReport() *models.QuotaResponse {
+	qt.mutex.Lock()
+	defer qt.mutex.Unlock()
+
+	qt.rollover(time.Now())
+
+	projectIDs := make(map[string]bool)
+	for projectID := range qt.usage {
+		projectIDs[projectID] = true
+	}
+	for projectID := range qt.projectLimits {
+		projectIDs[projectID] = true
+	}
+
+	response := &models.QuotaResponse{
+		Day:      qt.day.Format(time.DateOnly),
+		TimeZone: quotaLocation,
+		ResetAt:  qt.resetAt().UTC().Format(time.RFC3339),
+		Projects: make([]models.ProjectQuota, 0, len(projectIDs)),
+	}
+
+	for projectID := range projectIDs {
+		project := models.ProjectQuota{
+			ProjectID:       projectID,
+			Limit:           qt.limit(projectID),
+			ServiceAccounts: make([]models.ServiceAccountQuota, 0, len(qt.usage[projectID])),
+		}
+
+		for clientEmail, used := range qt.usage[projectID] {
+			project.Used += used
+			project.ServiceAccounts = append(project.ServiceAccounts, models.ServiceAccountQuota{
+				ClientEmail: clientEmail,
+				Used:        used,
+			})
+		}
+
+		if project.Limit > 0 {
+			remaining := max(project.Limit-project.Used, 0)
+			project.Remaining = &remaining
+		}
+
+		sort.Slice(project.ServiceAccounts, func(i, j int) bool {
+			return project.ServiceAccounts[i].ClientEmail < project.ServiceAccounts[j].ClientEmail
+		})
+		response.Projects = append(response.Projects, project)
+	}
+
+	sort.Slice(response.Projects, func(i, j int) bool {
+		return response.Projects[i].ProjectID < response.Projects[j].ProjectID
+	})
+
+	return response
+}
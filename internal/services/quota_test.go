@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"google-indexing-api/internal/models"
+	"google-indexing-api/internal/store"
+)
+
+func TestQuotaSeedCountsTheCurrentDay(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	history, err := store.OpenBoltStore(filepath.Join(t.TempDir(), "indexing.db"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	quota, err := newQuotaTracker(200, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quota.mutex.Lock()
+	quota.rollover(time.Now())
+	dayStart := quota.day
+	quota.mutex.Unlock()
+
+	records := []struct {
+		at        time.Time
+		success   bool
+		errorKind string
+		attempts  int
+	}{
+		{dayStart.Add(-time.Hour), true, "", 5},
+		{dayStart.Add(time.Second), true, "", 2},
+		{dayStart.Add(time.Second), false, models.ErrorKindGoogleAPI, 3},
+		// Never reached Google
+		{dayStart.Add(time.Second), false, models.ErrorKindNetwork, 4},
+	}
+	for _, r := range records {
+		_, err := history.RecordNotification(context.Background(), models.NotificationRecord{
+			URL:            "https://example.com/",
+			Type:           models.NotificationTypeUpdated,
+			ServiceAccount: "sa@p1",
+			ProjectID:      "p1",
+			Success:        r.success,
+			ErrorKind:      r.errorKind,
+			Attempts:       r.attempts,
+			NotifyTime:     r.at.UTC().Format(time.RFC3339Nano),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := quota.seed(context.Background(), history); err != nil {
+		t.Fatal(err)
+	}
+
+	if used := quota.accountUsed("p1", "sa@p1"); used != 5 {
+		t.Errorf("got %d calls used today, want 5", used)
+	}
+}
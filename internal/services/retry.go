@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/googleapi"
+
+	"google-indexing-api/internal/metrics"
+)
+
+// maxRetryDelay caps both the exponential backoff and any Retry-After
+// requested by Google.
+const maxRetryDelay = time.Minute
+
+// retryPolicy controls how transient Google API failures are retried.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+}
+
+func newRetryPolicy(maxRetries int, baseDelay time.Duration) retryPolicy {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	return retryPolicy{
+		maxAttempts: maxRetries + 1,
+		baseDelay:   baseDelay,
+	}
+}
+
+// call runs fn on the worker pool under its own deadline. The deadline starts
+// once a worker picks the call up, so time spent queued does not count.
+// operation names the Google API method in metrics.
+func (gis *GoogleIndexingService) call(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	return gis.workers.do(ctx, func() error {
+		callCtx, cancel := context.WithTimeout(ctx, gis.requestTimeout)
+		defer cancel()
+
+		err := fn(callCtx)
+		if err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("%w after %s: %v", ErrCallTimeout, gis.requestTimeout, err)
+		}
+
+		metrics.GoogleAPICalls.WithLabelValues(operation, resultCode(err)).Inc()
+
+		return err
+	})
+}
+
+// withRetry makes calls to fn until one succeeds, fails with an error that is
+// not worth retrying, or the attempt budget runs out. priorAttempts is the
+// number of attempts already spent on the same notification elsewhere, e.g.
+// inside a batch call. throttle, when set, is waited on before every attempt.
+// It returns the total number of attempts made and the time spent in throttle.
+//
+// The worker is released between attempts, and throttle is waited on before
+// taking one, so neither a backoff nor the rate limit blocks other calls.
+func (gis *GoogleIndexingService) withRetry(ctx context.Context, operation string, priorAttempts int, throttle func(ctx context.Context) (time.Duration, error), fn func(ctx context.Context) error) (int, time.Duration, error) {
+	attempt := priorAttempts
+	var waited time.Duration
+
+	for {
+		if throttle != nil {
+			wait, err := throttle(ctx)
+			waited += wait
+			if err != nil {
+				return attempt, waited, err
+			}
+		}
+
+		attempt++
+
+		err := gis.call(ctx, operation, fn)
+		if err == nil || attempt >= gis.retry.maxAttempts || !isRetryableError(ctx, err) {
+			return attempt, waited, err
+		}
+
+		delay := gis.retry.delay(attempt, err)
+		metrics.GoogleAPIRetries.WithLabelValues(operation).Inc()
+
+		gis.logger.WithError(err).WithFields(logrus.Fields{
+			"operation": operation,
+			"attempt":   attempt,
+			"delay":     delay.String(),
+		}).Warn("Google API call failed, retrying")
+
+		if !sleep(ctx, delay) {
+			return attempt, waited, err
+		}
+	}
+}
+
+// sleep waits for delay and reports whether it did, or false if ctx was done
+// first.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// delay returns how long to wait before the next attempt. A Retry-After sent
+// by Google wins over the exponential backoff.
+func (p retryPolicy) delay(attempt int, err error) time.Duration {
+	if retryAfter, ok := retryAfterDelay(err); ok {
+		return retryAfter
+	}
+
+	backoff := p.baseDelay << uint(attempt-1)
+	if backoff <= 0 || backoff > maxRetryDelay {
+		backoff = maxRetryDelay
+	}
+
+	// Add up to 50% jitter so concurrent retries do not hit Google in lockstep
+	jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+
+	return backoff + jitter
+}
+
+// isRetryableError reports whether err is a transient failure: a 429, 500 or
+// 503 from Google, a network error or a call timeout. Nothing is retried once
+// ctx is done, and exhausted quota is not retried when the call can move to
+// another pool member instead.
+func isRetryableError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if canFailOver(ctx) && isQuotaExhausted(err) {
+		return false
+	}
+
+	if errors.Is(err, ErrCallTimeout) {
+		return true
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable:
+			return true
+		default:
+			return false
+		}
+	}
+
+	return isNetworkError(err)
+}
+
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// retryAfterDelay reads the Retry-After header of a Google API error, given
+// either in seconds or as an HTTP date.
+func retryAfterDelay(err error) (time.Duration, bool) {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Header == nil {
+		return 0, false
+	}
+
+	value := apiErr.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		delay = time.Until(at)
+	} else {
+		return 0, false
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	return delay, true
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"google-indexing-api/internal/models"
+)
+
+// credentialFingerprint identifies one specific key of a service account: its
+// email, private_key_id and a hash of the private key itself. Rotating or
+// replacing the key changes the fingerprint, so a cached client is only ever
+// reused for the exact credentials it was built from.
+func credentialFingerprint(serviceAccount *models.ServiceAccountCredentials) string {
+	keyHash := sha256.Sum256([]byte(serviceAccount.PrivateKey))
+
+	hash := sha256.New()
+	hash.Write([]byte(serviceAccount.ClientEmail))
+	hash.Write([]byte{0})
+	hash.Write([]byte(serviceAccount.PrivateKeyID))
+	hash.Write([]byte{0})
+	hash.Write(keyHash[:])
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// serviceCache holds indexing clients keyed by credential fingerprint. Entries
+// expire ttl after they were created, and once maxEntries is reached the least
+// recently used entry is evicted to make room. A service account has at most
+// one entry: caching a client for a new key replaces the one for the old key.
+type serviceCache struct {
+	mutex      sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List // front is the most recently used entry
+
+	hits         uint64
+	misses       uint64
+	evictions    uint64
+	expirations  uint64
+	replacements uint64
+}
+
+type serviceCacheEntry struct {
+	key         string
+	clientEmail string
+	client      *indexingClient
+	createdAt   time.Time
+	lastUsed    time.Time
+}
+
+// newServiceCache creates a cache. A non-positive ttl disables expiry and a
+// non-positive maxEntries disables the size limit.
+func newServiceCache(ttl time.Duration, maxEntries int) *serviceCache {
+	return &serviceCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// get returns the cached client for key, counting a hit or a miss. Expired
+// entries are removed and reported as misses.
+func (c *serviceCache) get(key string) (*indexingClient, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		c.misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*serviceCacheEntry)
+	now := time.Now()
+
+	if c.expired(entry, now) {
+		c.remove(element)
+		c.expirations++
+		c.misses++
+		return nil, false
+	}
+
+	entry.lastUsed = now
+	c.lru.MoveToFront(element)
+	c.hits++
+
+	return entry.client, true
+}
+
+// add stores client under key, dropping any entry built from another key of the
+// same service account, then expired entries, then least recently used ones
+// while the cache is full.
+func (c *serviceCache) add(key string, clientEmail string, client *indexingClient) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+
+	if element, exists := c.entries[key]; exists {
+		entry := element.Value.(*serviceCacheEntry)
+		entry.client = client
+		entry.createdAt = now
+		entry.lastUsed = now
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	for element := c.lru.Front(); element != nil; {
+		next := element.Next()
+		if element.Value.(*serviceCacheEntry).clientEmail == clientEmail {
+			c.remove(element)
+			c.replacements++
+		}
+		element = next
+	}
+
+	c.removeExpired(now)
+
+	for c.maxEntries > 0 && c.lru.Len() >= c.maxEntries {
+		c.remove(c.lru.Back())
+		c.evictions++
+	}
+
+	c.entries[key] = c.lru.PushFront(&serviceCacheEntry{
+		key:         key,
+		clientEmail: clientEmail,
+		client:      client,
+		createdAt:   now,
+		lastUsed:    now,
+	})
+}
+
+// clear drops every entry. Counters are kept.
+func (c *serviceCache) clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+// counters returns the hit and miss counts without collecting entry stats.
+func (c *serviceCache) counters() (hits uint64, misses uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.hits, c.misses
+}
+
+func (c *serviceCache) stats() models.CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	c.removeExpired(now)
+
+	stats := models.CacheStats{
+		CachedServices: c.lru.Len(),
+		MaxEntries:     c.maxEntries,
+		TTLSeconds:     int64(c.ttl.Seconds()),
+		Hits:           c.hits,
+		Misses:         c.misses,
+		Evictions:      c.evictions,
+		Expirations:    c.expirations,
+		Replacements:   c.replacements,
+		Entries:        make([]models.CacheEntryStats, 0, c.lru.Len()),
+		Timestamp:      now.UTC().Format(time.RFC3339),
+	}
+
+	for element := c.lru.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*serviceCacheEntry)
+
+		entryStats := models.CacheEntryStats{
+			ServiceAccount: entry.clientEmail,
+			Fingerprint:    entry.key[:16],
+			CreatedAt:      entry.createdAt.UTC().Format(time.RFC3339),
+			LastUsedAt:     entry.lastUsed.UTC().Format(time.RFC3339),
+			AgeSeconds:     int64(now.Sub(entry.createdAt).Seconds()),
+		}
+		if c.ttl > 0 {
+			entryStats.ExpiresInSeconds = int64(entry.createdAt.Add(c.ttl).Sub(now).Seconds())
+		}
+
+		stats.Entries = append(stats.Entries, entryStats)
+	}
+
+	return stats
+}
+
+func (c *serviceCache) expired(entry *serviceCacheEntry, now time.Time) bool {
+	return c.ttl > 0 && now.Sub(entry.createdAt) >= c.ttl
+}
+
+// removeExpired drops every expired entry. Callers must hold c.mutex.
+func (c *serviceCache) removeExpired(now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	for element := c.lru.Back(); element != nil; {
+		prev := element.Prev()
+		if c.expired(element.Value.(*serviceCacheEntry), now) {
+			c.remove(element)
+			c.expirations++
+		}
+		element = prev
+	}
+}
+
+// remove drops a single entry. Callers must hold c.mutex.
+func (c *serviceCache) remove(element *list.Element) {
+	entry := element.Value.(*serviceCacheEntry)
+	delete(c.entries, entry.key)
+	c.lru.Remove(element)
+}
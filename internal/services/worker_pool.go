@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// workerPool runs outbound calls to Google on a fixed number of workers shared
+// by every request, so the total number of calls in flight never exceeds its
+// size no matter how many batches are running.
+type workerPool struct {
+	tasks    chan func()
+	size     int
+	inFlight atomic.Int64
+}
+
+func newWorkerPool(size int) *workerPool {
+	if size < 1 {
+		size = 1
+	}
+
+	pool := &workerPool{
+		tasks: make(chan func()),
+		size:  size,
+	}
+
+	for i := 0; i < size; i++ {
+		go pool.work()
+	}
+
+	return pool
+}
+
+func (p *workerPool) work() {
+	for task := range p.tasks {
+		p.inFlight.Add(1)
+		task()
+		p.inFlight.Add(-1)
+	}
+}
+
+// do runs fn on a pool worker and waits for it to finish. If ctx is done before
+// a worker becomes free, fn is never run and the context error is returned.
+// fn must not call do itself, or it could wait on a worker held by its caller.
+func (p *workerPool) do(ctx context.Context, fn func() error) error {
+	var err error
+	done := make(chan struct{})
+
+	task := func() {
+		defer close(done)
+		err = fn()
+	}
+
+	select {
+	case p.tasks <- task:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	<-done
+	return err
+}
+
+// InFlight returns the number of calls currently running.
+func (p *workerPool) InFlight() int {
+	return int(p.inFlight.Load())
+}
+
+// Size returns the number of workers in the pool.
+func (p *workerPool) Size() int {
+	return p.size
+}
@@ -0,0 +1,301 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+
+	"google-indexing-api/internal/models"
+)
+
+var (
+	metaBucket          = []byte("meta")
+	notificationsBucket = []byte("notifications")
+	jobsBucket          = []byte("jobs")
+
+	// notificationsByURLBucket indexes notifications by URL. Keys are the URL,
+	// a zero byte and the notification key; values are empty.
+	notificationsByURLBucket = []byte("notifications_by_url")
+
+	schemaVersionKey = []byte("schema_version")
+)
+
+// notifyTimeSkew is how far the NotifyTime of a record may lag behind a newer
+// record's, since Google's clock and call latency decide it.
+const notifyTimeSkew = 5 * time.Minute
+
+// BoltStore is a Store backed by a single bbolt file.
+type BoltStore struct {
+	db     *bolt.DB
+	logger *logrus.Logger
+}
+
+// OpenBoltStore opens or creates the database at path and runs pending
+// migrations.
+func OpenBoltStore(path string, logger *logrus.Logger) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %v", err)
+	}
+
+	// A second process holding the file would otherwise block forever
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store %s: %v", path, err)
+	}
+
+	s := &BoltStore{
+		db:     db,
+		logger: logger,
+	}
+
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *BoltStore) RecordNotification(ctx context.Context, record models.NotificationRecord) (*models.NotificationRecord, error) {
+	// Batch coalesces concurrent writes from a batch submission into a few
+	// transactions instead of one fsync per URL
+	err := s.db.Batch(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(notificationsBucket)
+
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		record.ID = seq
+
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		key := sequenceKey(seq)
+		if err := bucket.Put(key, value); err != nil {
+			return err
+		}
+
+		return tx.Bucket(notificationsByURLBucket).Put(urlIndexKey(record.URL, key), nil)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record notification: %v", err)
+	}
+
+	return &record, nil
+}
+
+func (s *BoltStore) ListNotifications(ctx context.Context, query NotificationQuery) ([]models.NotificationRecord, bool, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	// Keys are in insertion order, so once records are older than Since
+	// nothing older can match. NotifyTime comes from Google when it sent one,
+	// which may trail the insertion order slightly
+	var stopBefore time.Time
+	if !query.Since.IsZero() {
+		stopBefore = query.Since.Add(-notifyTimeSkew)
+	}
+
+	records := make([]models.NotificationRecord, 0, limit)
+	more := false
+
+	// collect is called newest first and returns false to stop
+	collect := func(value []byte) (bool, error) {
+		var record models.NotificationRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			return false, err
+		}
+
+		if !stopBefore.IsZero() {
+			if notifyTime, err := time.Parse(time.RFC3339Nano, record.NotifyTime); err == nil && notifyTime.Before(stopBefore) {
+				return false, nil
+			}
+		}
+
+		if !matchNotification(&record, query) {
+			return true, nil
+		}
+		if len(records) == limit {
+			more = true
+			return false, nil
+		}
+
+		records = append(records, record)
+		return true, nil
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		notifications := tx.Bucket(notificationsBucket)
+
+		if query.URLPrefix != "" {
+			// Merge the index entries of every matching URL newest first
+			runs := newURLRuns(tx.Bucket(notificationsByURLBucket).Cursor(), []byte(query.URLPrefix), query.Before)
+			for runs.Len() > 0 {
+				key := runs.next()
+
+				value := notifications.Get(key)
+				if value == nil {
+					continue
+				}
+				if next, err := collect(value); err != nil || !next {
+					return err
+				}
+			}
+			return nil
+		}
+
+		cursor := notifications.Cursor()
+		k, v := cursor.Last()
+		if query.Before != 0 {
+			// Seek lands on the first key >= Before; step back below it
+			if k, v = cursor.Seek(sequenceKey(query.Before)); k == nil {
+				k, v = cursor.Last()
+			} else {
+				k, v = cursor.Prev()
+			}
+		}
+
+		for ; k != nil; k, v = cursor.Prev() {
+			if next, err := collect(v); err != nil || !next {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list notifications: %v", err)
+	}
+
+	return records, more, nil
+}
+
+// matchNotification applies every filter of query except the URL prefix and
+// cursor, which are handled while iterating.
+func matchNotification(record *models.NotificationRecord, query NotificationQuery) bool {
+	if query.URLPrefix != "" && !strings.HasPrefix(record.URL, query.URLPrefix) {
+		return false
+	}
+	if query.Type != "" && record.Type != query.Type {
+		return false
+	}
+	if query.Success != nil && record.Success != *query.Success {
+		return false
+	}
+	if query.ServiceAccount != "" && !strings.EqualFold(record.ServiceAccount, query.ServiceAccount) {
+		return false
+	}
+
+	if query.Host != "" {
+		parsed, err := url.Parse(record.URL)
+		if err != nil || !strings.EqualFold(parsed.Hostname(), query.Host) {
+			return false
+		}
+	}
+
+	if !query.Since.IsZero() || !query.Until.IsZero() {
+		notifyTime, err := time.Parse(time.RFC3339Nano, record.NotifyTime)
+		if err != nil {
+			return false
+		}
+		if !query.Since.IsZero() && notifyTime.Before(query.Since) {
+			return false
+		}
+		if !query.Until.IsZero() && !notifyTime.Before(query.Until) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *BoltStore) SaveJob(ctx context.Context, job models.JobResponse) error {
+	value, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).Put([]byte(job.ID), value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save job: %v", err)
+	}
+
+	return nil
+}
+
+func (s *BoltStore) GetJob(ctx context.Context, id string) (*models.JobResponse, error) {
+	var job models.JobResponse
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(jobsBucket).Get([]byte(id))
+		if value == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(value, &job)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (s *BoltStore) ListJobs(ctx context.Context) ([]models.JobResponse, error) {
+	var jobs []models.JobResponse
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(_, value []byte) error {
+			var job models.JobResponse
+			if err := json.Unmarshal(value, &job); err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %v", err)
+	}
+
+	return jobs, nil
+}
+
+func (s *BoltStore) DeleteJob(ctx context.Context, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).Delete([]byte(id))
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// urlIndexKey builds the key of a notification in the URL index.
+func urlIndexKey(url string, key []byte) []byte {
+	indexKey := make([]byte, 0, len(url)+1+len(key))
+	indexKey = append(indexKey, url...)
+	indexKey = append(indexKey, 0)
+	return append(indexKey, key...)
+}
+
+// sequenceKey encodes seq big-endian, so keys sort in insertion order.
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
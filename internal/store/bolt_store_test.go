@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+
+	"google-indexing-api/internal/models"
+)
+
+func openTestStore(t *testing.T) *BoltStore {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	s, err := OpenBoltStore(filepath.Join(t.TempDir(), "indexing.db"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func recordAt(t *testing.T, s *BoltStore, url string, at time.Time) uint64 {
+	t.Helper()
+
+	record, err := s.RecordNotification(context.Background(), models.NotificationRecord{
+		URL:        url,
+		Type:       models.NotificationTypeUpdated,
+		Success:    true,
+		NotifyTime: at.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return record.ID
+}
+
+func recordIDs(records []models.NotificationRecord) []uint64 {
+	ids := make([]uint64, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+	}
+	return ids
+}
+
+func equalIDs(a []uint64, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListNotificationsURLPrefixPages(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Now()
+
+	var want []uint64
+	for _, url := range []string{
+		"https://a.test/2", "https://a.test/1", "https://b.test/1", "https://a.test/10",
+		"https://a.test/1", "https://a.test", "https://a.test/2", "https://b.test/1",
+	} {
+		id := recordAt(t, s, url, now)
+		if url != "https://b.test/1" && url != "https://a.test" {
+			want = append([]uint64{id}, want...)
+		}
+	}
+
+	var got []uint64
+	query := NotificationQuery{URLPrefix: "https://a.test/", Limit: 2}
+	for {
+		records, more, err := s.ListNotifications(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, recordIDs(records)...)
+		if !more {
+			break
+		}
+		query.Before = records[len(records)-1].ID
+	}
+
+	if !equalIDs(got, want) {
+		t.Errorf("got IDs %v, want %v newest first", got, want)
+	}
+}
+
+func TestListNotificationsStopsAtSince(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Now()
+
+	// An undecodable record older than Since fails any walk reaching it
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(notificationsBucket)
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(sequenceKey(seq), []byte("{")); err != nil {
+			return err
+		}
+		return tx.Bucket(notificationsByURLBucket).Put(urlIndexKey("https://a.test/old", sequenceKey(seq)), nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recordAt(t, s, "https://a.test/old", now.Add(-48*time.Hour))
+	first := recordAt(t, s, "https://a.test/new", now.Add(-time.Minute))
+	second := recordAt(t, s, "https://a.test/new", now)
+
+	for _, query := range []NotificationQuery{
+		{Since: now.Add(-time.Hour)},
+		{Since: now.Add(-time.Hour), URLPrefix: "https://a.test/"},
+	} {
+		records, more, err := s.ListNotifications(context.Background(), query)
+		if err != nil {
+			t.Fatalf("url_prefix %q: %v", query.URLPrefix, err)
+		}
+		if got := recordIDs(records); more || !equalIDs(got, []uint64{second, first}) {
+			t.Errorf("url_prefix %q: got IDs %v (more %v), want %v", query.URLPrefix, got, more, []uint64{second, first})
+		}
+	}
+
+	if _, _, err := s.ListNotifications(context.Background(), NotificationQuery{}); err == nil {
+		t.Error("a walk without Since did not reach the undecodable record")
+	}
+}
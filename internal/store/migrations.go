@@ -0,0 +1,82 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// migration upgrades the schema by one version. Pending migrations run in
+// order in a single transaction, so a failing one leaves the store untouched.
+// Released migrations are never edited: add a new one instead.
+type migration struct {
+	description string
+	apply       func(tx *bolt.Tx) error
+}
+
+var migrations = []migration{
+	{
+		description: "create notification and job buckets",
+		apply: func(tx *bolt.Tx) error {
+			if _, err := tx.CreateBucketIfNotExists(notificationsBucket); err != nil {
+				return err
+			}
+			_, err := tx.CreateBucketIfNotExists(jobsBucket)
+			return err
+		},
+	},
+	{
+		description: "index notifications by URL",
+		apply: func(tx *bolt.Tx) error {
+			index, err := tx.CreateBucketIfNotExists(notificationsByURLBucket)
+			if err != nil {
+				return err
+			}
+
+			return tx.Bucket(notificationsBucket).ForEach(func(key, value []byte) error {
+				var record struct {
+					URL string `json:"url"`
+				}
+				if err := json.Unmarshal(value, &record); err != nil {
+					return err
+				}
+				return index.Put(urlIndexKey(record.URL, key), nil)
+			})
+		},
+	},
+}
+
+// migrate applies every migration newer than the stored schema version.
+func (s *BoltStore) migrate() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		version := 0
+		if value := meta.Get(schemaVersionKey); len(value) == 8 {
+			version = int(binary.BigEndian.Uint64(value))
+		}
+
+		if version > len(migrations) {
+			return fmt.Errorf("store schema version %d is newer than this build supports (%d)", version, len(migrations))
+		}
+
+		for i := version; i < len(migrations); i++ {
+			if err := migrations[i].apply(tx); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %v", i+1, migrations[i].description, err)
+			}
+
+			s.logger.WithFields(logrus.Fields{
+				"version":     i + 1,
+				"description": migrations[i].description,
+			}).Info("Applied store migration")
+		}
+
+		return meta.Put(schemaVersionKey, sequenceKey(uint64(len(migrations))))
+	})
+}
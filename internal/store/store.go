@@ -0,0 +1,55 @@
+// Package store persists submission history and batch jobs.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google-indexing-api/internal/models"
+)
+
+// ErrNotFound is returned when a record does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// NotificationQuery filters the submission history. Zero values match
+// everything.
+type NotificationQuery struct {
+	URLPrefix      string
+	Host           string
+	ServiceAccount string
+	Type           string
+	Success        *bool
+	Since          time.Time
+	Until          time.Time
+
+	// Before only returns records with a lower ID, for cursor pagination.
+	Before uint64
+	Limit  int
+}
+
+// Store is the persistence layer used by the indexing service and the job
+// manager.
+type Store interface {
+	// RecordNotification appends a notification sent to Google and returns it
+	// with its ID set.
+	RecordNotification(ctx context.Context, record models.NotificationRecord) (*models.NotificationRecord, error)
+
+	// ListNotifications returns records matching query, newest first. more
+	// reports whether further records match after the last one returned.
+	ListNotifications(ctx context.Context, query NotificationQuery) (records []models.NotificationRecord, more bool, err error)
+
+	// SaveJob creates or replaces a job.
+	SaveJob(ctx context.Context, job models.JobResponse) error
+
+	// GetJob returns a job, or ErrNotFound.
+	GetJob(ctx context.Context, id string) (*models.JobResponse, error)
+
+	// ListJobs returns every stored job.
+	ListJobs(ctx context.Context) ([]models.JobResponse, error)
+
+	// DeleteJob removes a job. Deleting a missing job is not an error.
+	DeleteJob(ctx context.Context, id string) error
+
+	Close() error
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"bytes"
+	"container/heap"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// urlRuns walks the URL index entries under a prefix newest first without
+// loading them all. Entries of one URL are stored in insertion order, so each
+// URL is a run walked backwards; the heap holds the current, newest unvisited
+// entry of every run. Memory grows with the number of URLs, not of records.
+type urlRuns struct {
+	cursor *bolt.Cursor
+	runs   []urlRun
+}
+
+type urlRun struct {
+	// prefix is the URL followed by the zero separator.
+	prefix []byte
+	// key is the notification key of the current entry.
+	key []byte
+}
+
+// newURLRuns finds every URL under prefix in the index and positions its run
+// on its newest entry below before, or its newest entry when before is 0.
+func newURLRuns(cursor *bolt.Cursor, prefix []byte, before uint64) *urlRuns {
+	r := &urlRuns{cursor: cursor}
+
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); {
+		runPrefix := append([]byte(nil), k[:len(k)-8]...)
+
+		// The separator is the lowest byte, so this sorts after every entry
+		// of the URL and before any longer URL
+		end := append(runPrefix[:len(runPrefix)-1:len(runPrefix)-1], 1)
+
+		upper := end
+		if before != 0 {
+			upper = append(runPrefix[:len(runPrefix):len(runPrefix)], sequenceKey(before)...)
+		}
+		if key := r.below(runPrefix, upper); key != nil {
+			r.runs = append(r.runs, urlRun{prefix: runPrefix, key: key})
+		}
+
+		k, _ = cursor.Seek(end)
+	}
+
+	heap.Init(r)
+	return r
+}
+
+// next returns the newest unvisited notification key and moves its run back.
+func (r *urlRuns) next() []byte {
+	run := &r.runs[0]
+	key := run.key
+
+	if previous := r.below(run.prefix, append(run.prefix[:len(run.prefix):len(run.prefix)], key...)); previous != nil {
+		run.key = previous
+		heap.Fix(r, 0)
+	} else {
+		heap.Pop(r)
+	}
+
+	return key
+}
+
+// below returns the notification key of the last entry of the run before
+// upper, or nil when there is none.
+func (r *urlRuns) below(runPrefix []byte, upper []byte) []byte {
+	k, _ := r.cursor.Seek(upper)
+	if k == nil {
+		k, _ = r.cursor.Last()
+	} else {
+		k, _ = r.cursor.Prev()
+	}
+
+	if k == nil || len(k) != len(runPrefix)+8 || !bytes.HasPrefix(k, runPrefix) {
+		return nil
+	}
+
+	return append([]byte(nil), k[len(runPrefix):]...)
+}
+
+func (r *urlRuns) Len() int           { return len(r.runs) }
+func (r *urlRuns) Less(i, j int) bool { return bytes.Compare(r.runs[i].key, r.runs[j].key) > 0 }
+func (r *urlRuns) Swap(i, j int)      { r.runs[i], r.runs[j] = r.runs[j], r.runs[i] }
+func (r *urlRuns) Push(x any)         { r.runs = append(r.runs, x.(urlRun)) }
+
+func (r *urlRuns) Pop() any {
+	run := r.runs[len(r.runs)-1]
+	r.runs = r.runs[:len(r.runs)-1]
+	return run
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a thread-safe token bucket that refills at a constant rate
+// up to its capacity.
+type TokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+// NewTokenBucket returns a full bucket holding capacity tokens that refills at
+// refillPerSecond tokens per second.
+func NewTokenBucket(capacity int, refillPerSecond float64) *TokenBucket {
+	return &TokenBucket{
+		capacity: float64(capacity),
+		tokens:   float64(capacity),
+		rate:     refillPerSecond,
+		last:     time.Now(),
+	}
+}
+
+// Take removes one token if one is available. It returns whether a token was
+// taken, the whole tokens left afterwards, and how long until the next token
+// becomes available when none was.
+func (b *TokenBucket) Take() (bool, int, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, int(b.tokens), 0
+	}
+
+	return false, 0, b.durationFor(1 - b.tokens)
+}
+
+// UntilFull returns how long the bucket needs to refill completely.
+func (b *TokenBucket) UntilFull() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	return b.durationFor(b.capacity - b.tokens)
+}
+
+// LastUsed returns the last time the bucket was touched.
+func (b *TokenBucket) LastUsed() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.last
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+func (b *TokenBucket) durationFor(tokens float64) time.Duration {
+	if tokens <= 0 || b.rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(tokens / b.rate * float64(time.Second))
+}
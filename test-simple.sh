@@ -2,6 +2,8 @@
 
 # Simple test script for Google Indexing API
 BASE_URL="http://localhost:8080"
+# Any API key with at least the viewer role
+API_KEY="your-api-key-here"
 
 echo "🚀 Testing Google Indexing API"
 echo "================================"
@@ -39,7 +41,8 @@ echo ""
 
 # Test 3: Cache Stats
 echo "3. Testing Cache Stats..."
-curl -s "$BASE_URL/api/v1/cache/stats" | jq .
+curl -s "$BASE_URL/api/v1/cache/stats" \
+  -H "X-API-Key: $API_KEY" | jq .
 echo ""
 
 echo "✅ Test completed!"